@@ -4,11 +4,14 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"github.com/go-webauthn/webauthn/webauthn"
 	"github.com/sirupsen/logrus"
 	"net"
 	"net/http"
 	"surge/internal/conf"
+	"surge/internal/mailer"
 	"surge/internal/schema"
+	"surge/internal/sms"
 	"surge/internal/storage"
 	"time"
 )
@@ -21,6 +24,11 @@ type SurgeAPI struct {
 	db      *sql.DB
 	queries *schema.Queries
 
+	mailer    mailer.Mailer
+	smsSender sms.SMSSender
+	// webAuthn is nil if WebAuthn is not configured
+	webAuthn *webauthn.WebAuthn
+
 	config *conf.SurgeConfigurations
 }
 
@@ -28,10 +36,24 @@ type SurgeAPI struct {
 func NewSurgeAPI(config *conf.SurgeConfigurations) SurgeAPI {
 	conn := storage.CreateDatabaseConnection(&config.Database)
 	api := SurgeAPI{
-		version: nil,
-		config:  config,
-		db:      conn,
-		queries: storage.CreateQueries(conn),
+		version:   nil,
+		config:    config,
+		db:        conn,
+		queries:   storage.CreateQueries(conn),
+		mailer:    mailer.NewMailer(&config.Mailer),
+		smsSender: sms.NewSMSSender(&config.SMS),
+	}
+
+	if config.WebAuthn.Enabled() {
+		webAuthn, err := webauthn.New(&webauthn.Config{
+			RPID:          config.WebAuthn.RPID,
+			RPDisplayName: config.WebAuthn.RPDisplayName,
+			RPOrigins:     config.WebAuthn.RPOrigins,
+		})
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to configure webauthn")
+		}
+		api.webAuthn = webAuthn
 	}
 
 	api.httpHandler = api.createHttpHandler()
@@ -74,6 +96,15 @@ func (a *SurgeAPI) ListenAndServe(ctx context.Context, hostAndPort string) {
 		}
 	}()
 
+	if a.config.Auth.PurgeDeletedUsersAfter > 0 {
+		cleanupWaitGroup.Add(1)
+		go func() {
+			defer cleanupWaitGroup.Done()
+
+			a.purgeDeletedUsers(ctx)
+		}()
+	}
+
 	logger.Infof("Listening on %s\n", hostAndPort)
 
 	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
@@ -2,10 +2,12 @@ package api
 
 import (
 	"context"
+	"crypto/subtle"
 	"fmt"
 	"github.com/golang-jwt/jwt/v5"
 	"net/http"
 	"regexp"
+	"slices"
 	"surge/internal/conf"
 )
 
@@ -28,6 +30,40 @@ func (a *SurgeAPI) useAuthentication(w http.ResponseWriter, r *http.Request) (co
 	return ctx, err
 }
 
+// useOptionalAuthentication authenticates the request only if it has an Authorization header
+func (a *SurgeAPI) useOptionalAuthentication(w http.ResponseWriter, r *http.Request) (context.Context, error) {
+	if r.Header.Get("Authorization") == "" {
+		return r.Context(), nil
+	}
+
+	return a.useAuthentication(w, r)
+}
+
+// useAdminAuthentication authenticates the request with the service role key, or a JWT with a role claim allowed to administrate
+func (a *SurgeAPI) useAdminAuthentication(w http.ResponseWriter, r *http.Request) (context.Context, error) {
+	token, err := a.getBearerAuthorizationHeader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	serviceRoleKey := a.config.Admin.ServiceRoleKey
+	if serviceRoleKey != "" && subtle.ConstantTimeCompare([]byte(token), []byte(serviceRoleKey)) == 1 {
+		return r.Context(), nil
+	}
+
+	ctx, err := a.parseJWTClaims(token, r)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := getClaims(ctx)
+	if claims.Role == "" || !slices.Contains(a.config.Admin.Roles, claims.Role) {
+		return nil, ForbiddenError(ErrorCodeNotAdmin, "this endpoint requires an admin token")
+	}
+
+	return ctx, nil
+}
+
 func (a *SurgeAPI) getBearerAuthorizationHeader(r *http.Request) (string, error) {
 	authHeader := r.Header.Get("Authorization")
 
@@ -3,6 +3,7 @@ package api
 import (
 	"context"
 	"github.com/golang-jwt/jwt/v5"
+	"surge/internal/schema"
 )
 
 type contextKey string
@@ -36,3 +37,13 @@ func getClaims(ctx context.Context) *AccessTokenClaims {
 	}
 	return token.Claims.(*AccessTokenClaims)
 }
+
+// getTargetUser reads the user an external identity is being linked to, nil if not linking
+func getTargetUser(ctx context.Context) *schema.AuthUser {
+	obj := ctx.Value(contextTargetUserKey)
+	if obj == nil {
+		return nil
+	}
+
+	return obj.(*schema.AuthUser)
+}
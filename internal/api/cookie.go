@@ -7,6 +7,8 @@ import (
 	"time"
 )
 
+const linkingNonceCookieName = "linking-nonce"
+
 // setCookieTokens sets the access_token & refresh_token in the cookies
 func (a *SurgeAPI) setCookieTokens(config *conf.SurgeConfigurations, token *AccessTokenResponse, session bool, w http.ResponseWriter) error {
 	// don't need to catch error here since we always set the cookie name
@@ -38,6 +40,22 @@ func (a *SurgeAPI) setCookieToken(config *conf.SurgeConfigurations, name string,
 	return nil
 }
 
+// setCookieLinkingNonce binds linking an identity to the browser which started it, so the state of linking can't be
+// handed to someone else. SameSite is none as providers using response_mode=form_post post the callback cross-site.
+func (a *SurgeAPI) setCookieLinkingNonce(config *conf.SurgeConfigurations, nonce string, expiresAfter time.Duration, w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     config.Cookie.Key + "-" + linkingNonceCookieName,
+		Value:    nonce,
+		Expires:  time.Now().Add(expiresAfter),
+		MaxAge:   int(expiresAfter.Seconds()),
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteNoneMode,
+		Path:     "/",
+		Domain:   config.Cookie.Domain,
+	})
+}
+
 func (a *SurgeAPI) clearCookieTokens(config *conf.SurgeConfigurations, w http.ResponseWriter) {
 	a.clearCookieToken(config, "access-token", w)
 	a.clearCookieToken(config, "refresh-token", w)
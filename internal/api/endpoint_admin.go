@@ -0,0 +1,333 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"surge/internal/auth"
+	"surge/internal/schema"
+	"surge/internal/storage"
+	"surge/internal/utilities"
+	"time"
+)
+
+const (
+	adminUsersDefaultPerPage = 50
+	adminUsersMaxPerPage     = 1000
+)
+
+// EndpointAdminUsers lists users page by page, optionally filtered by query matching email, username or phone
+func (a *SurgeAPI) EndpointAdminUsers(w http.ResponseWriter, r *http.Request) error {
+	query := r.URL.Query()
+
+	page, err := parsePositiveIntQuery(query.Get("page"), 1)
+	if err != nil {
+		return BadRequestError(ErrorCodeInvalidField, "page must be a positive integer")
+	}
+	perPage, err := parsePositiveIntQuery(query.Get("per_page"), adminUsersDefaultPerPage)
+	if err != nil || perPage > adminUsersMaxPerPage {
+		return BadRequestError(ErrorCodeInvalidField, "per_page must be a positive integer up to %d", adminUsersMaxPerPage)
+	}
+	// The offset has to fit the int32 the query takes
+	if page-1 > math.MaxInt32/perPage {
+		return BadRequestError(ErrorCodeInvalidField, "page is out of range")
+	}
+
+	// Matching is done with ilike, so wildcards typed by the admin are matched literally
+	search := escapeLikePattern(query.Get("query"))
+
+	users, err := a.queries.ListUsers(r.Context(), schema.ListUsersParams{
+		Query:  search,
+		Limit:  int32(perPage),
+		Offset: int32((page - 1) * perPage),
+	})
+	if err != nil {
+		return NewHTTPError(http.StatusInternalServerError, ErrorCodeDatabaseFailure, "unexpected database failure")
+	}
+
+	total, err := a.queries.CountUsers(r.Context(), search)
+	if err != nil {
+		return NewHTTPError(http.StatusInternalServerError, ErrorCodeDatabaseFailure, "unexpected database failure")
+	}
+
+	response := &AdminUsersResponse{
+		Users:   make([]*AdminUserResponse, len(users)),
+		Total:   total,
+		Page:    page,
+		PerPage: perPage,
+	}
+	for i, user := range users {
+		response.Users[i] = NewAdminUserResponse(user)
+	}
+
+	return writeResponseJSON(w, http.StatusOK, response)
+}
+
+// EndpointAdminUser returns the user in the url
+func (a *SurgeAPI) EndpointAdminUser(w http.ResponseWriter, r *http.Request) error {
+	user, err := a.getAdminTargetUser(r, a.queries)
+	if err != nil {
+		return err
+	}
+
+	return writeResponseJSON(w, http.StatusOK, NewAdminUserResponse(user))
+}
+
+// EndpointAdminCreateUser creates a user with the same validation rules as signing up
+func (a *SurgeAPI) EndpointAdminCreateUser(w http.ResponseWriter, r *http.Request) error {
+	body, err := utilities.GetBodyJson[AdminCreateUserRequest](r)
+	if err != nil {
+		return BadRequestError(ErrorCodeInvalidJSON, "failed to parse request body: %+v", err)
+	}
+
+	var createdUser *schema.AuthUser
+	err = a.Transaction(r.Context(), func(tx *sql.Tx, queries *schema.Queries) error {
+		createdUser, err = auth.CreateUser(queries, r.Context(), a.config, auth.CreateUserOptions{
+			Phone:    body.Phone,
+			Email:    body.Email,
+			Username: body.Username,
+			// Users created by administrators may sign in without password, e.g. with one-time passwords
+			Password: body.Password,
+			Metadata: auth.UserMetadata{
+				Avatar:    body.Metadata.Avatar,
+				FirstName: body.Metadata.FirstName,
+				LastName:  body.Metadata.LastName,
+				Birthdate: body.Metadata.Birthdate,
+			},
+		})
+		if err != nil {
+			return err
+		}
+
+		if body.EmailConfirm && createdUser.Email.Valid {
+			createdUser, err = auth.SetEmailConfirmed(queries, r.Context(), createdUser, true)
+			if err != nil {
+				return err
+			}
+		}
+		if body.PhoneConfirm && createdUser.Phone != nil {
+			createdUser, err = auth.SetPhoneConfirmed(queries, r.Context(), createdUser, true)
+		}
+		return err
+	})
+	if err != nil {
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) {
+			return httpErr
+		}
+		return userOptionsError(r, err)
+	}
+
+	return writeResponseJSON(w, http.StatusOK, NewAdminUserResponse(createdUser))
+}
+
+// EndpointAdminUpdateUser updates the user in the url, changed email or phone is unconfirmed unless confirmed in the body
+func (a *SurgeAPI) EndpointAdminUpdateUser(w http.ResponseWriter, r *http.Request) error {
+	body, err := utilities.GetBodyJson[AdminUpdateUserRequest](r)
+	if err != nil {
+		return BadRequestError(ErrorCodeInvalidJSON, "failed to parse request body: %+v", err)
+	}
+
+	var updatedUser *schema.AuthUser
+	err = a.Transaction(r.Context(), func(tx *sql.Tx, queries *schema.Queries) error {
+		user, err := a.getAdminTargetUser(r, queries)
+		if err != nil {
+			return err
+		}
+
+		updatedUser, err = auth.UpdateUser(queries, r.Context(), user, auth.UpdateUserOptions{
+			Email:    body.Email,
+			Username: body.Username,
+			Phone:    body.Phone,
+			Metadata: auth.UserMetadata{
+				Avatar:    body.Metadata.Avatar,
+				FirstName: body.Metadata.FirstName,
+				LastName:  body.Metadata.LastName,
+				Birthdate: body.Metadata.Birthdate,
+				Extra:     body.Metadata.Extra,
+			},
+		})
+		if err != nil {
+			return err
+		}
+
+		if body.Password != nil {
+			updatedUser, err = auth.SetPassword(queries, r.Context(), updatedUser, *body.Password)
+			if err != nil {
+				return err
+			}
+		}
+
+		emailConfirm := body.EmailConfirm
+		if emailConfirm == nil && updatedUser.Email != user.Email {
+			emailConfirm = new(bool)
+		}
+		if emailConfirm != nil {
+			updatedUser, err = auth.SetEmailConfirmed(queries, r.Context(), updatedUser, *emailConfirm)
+			if err != nil {
+				return err
+			}
+		}
+
+		phoneConfirm := body.PhoneConfirm
+		previousPhone := storage.InterfaceToStringPointer(user.Phone)
+		if phoneConfirm == nil && body.Phone != nil && (previousPhone == nil || *previousPhone != *body.Phone) {
+			phoneConfirm = new(bool)
+		}
+		if phoneConfirm != nil {
+			updatedUser, err = auth.SetPhoneConfirmed(queries, r.Context(), updatedUser, *phoneConfirm)
+		}
+		return err
+	})
+	if err != nil {
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) {
+			return httpErr
+		}
+		return userOptionsError(r, err)
+	}
+
+	return writeResponseJSON(w, http.StatusOK, NewAdminUserResponse(updatedUser))
+}
+
+// EndpointAdminBanUser bans the user in the url for a duration, signing the user out of every session
+func (a *SurgeAPI) EndpointAdminBanUser(w http.ResponseWriter, r *http.Request) error {
+	body, err := utilities.GetBodyJson[AdminBanUserRequest](r)
+	if err != nil {
+		return BadRequestError(ErrorCodeInvalidJSON, "failed to parse request body: %+v", err)
+	}
+
+	var bannedUntil *time.Time
+	switch body.Duration {
+	case "":
+		return BadRequestError(ErrorCodeMissingField, "duration is empty or missing")
+	case "none":
+	default:
+		duration, err := time.ParseDuration(body.Duration)
+		if err != nil || duration <= 0 {
+			return BadRequestError(ErrorCodeInvalidField, "duration must be a positive duration such as \"24h\" or \"none\"")
+		}
+		until := time.Now().Add(duration)
+		bannedUntil = &until
+	}
+
+	var bannedUser *schema.AuthUser
+	err = a.Transaction(r.Context(), func(tx *sql.Tx, queries *schema.Queries) error {
+		user, err := a.getAdminTargetUser(r, queries)
+		if err != nil {
+			return err
+		}
+
+		bannedUser, err = auth.BanUser(queries, r.Context(), user, bannedUntil)
+		return err
+	})
+	if err != nil {
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) {
+			return httpErr
+		}
+		return InternalServerError("failed to ban user")
+	}
+
+	return writeResponseJSON(w, http.StatusOK, NewAdminUserResponse(bannedUser))
+}
+
+// EndpointAdminDeleteUser deletes the user in the url, the soft_delete query parameter overrides whether to soft delete
+func (a *SurgeAPI) EndpointAdminDeleteUser(w http.ResponseWriter, r *http.Request) error {
+	soft := a.config.Auth.SoftDeleteUsers
+	if value := r.URL.Query().Get("soft_delete"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return BadRequestError(ErrorCodeInvalidField, "soft_delete must be a boolean")
+		}
+		soft = parsed
+	}
+
+	err := a.Transaction(r.Context(), func(tx *sql.Tx, queries *schema.Queries) error {
+		user, err := a.getAdminTargetUser(r, queries)
+		if err != nil {
+			return err
+		}
+
+		return a.deleteUser(r, queries, user, soft, userDeletedByAdmin)
+	})
+	if err != nil {
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) {
+			return httpErr
+		}
+		return InternalServerError("failed to delete user")
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// EndpointAdminSignOutUser signs the user in the url out of every session
+func (a *SurgeAPI) EndpointAdminSignOutUser(w http.ResponseWriter, r *http.Request) error {
+	err := a.Transaction(r.Context(), func(tx *sql.Tx, queries *schema.Queries) error {
+		user, err := a.getAdminTargetUser(r, queries)
+		if err != nil {
+			return err
+		}
+
+		return a.revokeAllSessions(r.Context(), queries, user.ID)
+	})
+	if err != nil {
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) {
+			return httpErr
+		}
+		return InternalServerError("failed to sign out user")
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// getAdminTargetUser reads the user in the url
+func (a *SurgeAPI) getAdminTargetUser(r *http.Request, queries *schema.Queries) (*schema.AuthUser, error) {
+	userId, err := uuid.Parse(chi.URLParam(r, "user_id"))
+	if err != nil {
+		return nil, BadRequestError(ErrorCodeInvalidField, "user id is not a uuid")
+	}
+
+	user, err := queries.GetUser(r.Context(), userId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, NotFoundError(ErrorCodeUserNotFound, "user not found")
+		}
+		return nil, NewHTTPError(http.StatusInternalServerError, ErrorCodeDatabaseFailure, "unexpected database failure")
+	}
+
+	return user, nil
+}
+
+// likePatternReplacer escapes wildcards of like patterns with the default escape character
+var likePatternReplacer = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// escapeLikePattern makes the value match itself literally in a like pattern
+func escapeLikePattern(value string) string {
+	return likePatternReplacer.Replace(value)
+}
+
+// parsePositiveIntQuery parses a positive query parameter, returning fallback if it is absent
+func parsePositiveIntQuery(value string, fallback int) (int, error) {
+	if value == "" {
+		return fallback, nil
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, err
+	}
+	if parsed < 1 {
+		return 0, strconv.ErrRange
+	}
+	return parsed, nil
+}
@@ -2,19 +2,25 @@ package api
 
 import (
 	"context"
+	"crypto/hmac"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
+	"github.com/sqlc-dev/pqtype"
 	"golang.org/x/oauth2"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 	"surge/internal/api/provider"
 	"surge/internal/auth"
 	"surge/internal/schema"
+	"surge/internal/storage"
+	"surge/internal/utilities"
 	"time"
 )
 
@@ -23,10 +29,15 @@ type ExternalProviderClaims struct {
 	Provider        string `json:"provider"`
 	Referrer        string `json:"referrer,omitempty"`
 	LinkingTargetID string `json:"linking_target_id,omitempty"`
+	// LinkingNonceHash is the hash of the nonce in the cookie of the browser which started linking
+	LinkingNonceHash string `json:"linking_nonce_hash,omitempty"`
 }
 
+// externalStateExpiresAfter is how long the user has to sign in with the provider
+const externalStateExpiresAfter = 5 * time.Minute
+
 func (a *SurgeAPI) EndpointExternal(w http.ResponseWriter, r *http.Request) error {
-	targetUrl, err := a.GetExternalProviderUrl(w, r)
+	targetUrl, err := a.GetExternalProviderUrl(w, r, "")
 	if err != nil {
 		return err
 	}
@@ -62,46 +73,20 @@ func (a *SurgeAPI) internalExternalProviderCallback(w http.ResponseWriter, r *ht
 		return InternalServerError("failed to acquire user emails from provider %s: there was no email given", providerType)
 	}
 
-	// Reset
-	userData.Claims.EmailVerified = false
-	for _, email := range userData.Emails {
-		userData.Claims.Email = email.Email
-		userData.Claims.EmailVerified = email.Verified
-
-		if email.Primary {
-			break
-		}
-	}
+	selectPrimaryEmail(userData)
 
-	var user *schema.AuthUser
-	var identity *schema.AuthIdentity
-	identity, err = a.queries.GetIdentity(r.Context(), schema.GetIdentityParams{
-		Provider:   providerType,
-		ProviderID: userData.Claims.Subject,
-	})
+	user, err := a.findOrCreateExternalUser(r, providerType, userData)
 	if err != nil {
-		if !errors.Is(sql.ErrNoRows, err) {
-			return InternalServerError("database failed to find existing identity: %+v", err)
-		}
+		return err
+	}
 
-		user, identity, err = auth.CreateUserAndIdentity(a.queries, r.Context(), auth.CreateUserAndIdentityOptions{
-			Provider:          providerType,
-			ProviderAccountID: userData.Claims.Subject,
-			ProviderData:      *userData,
-		})
-		if err != nil {
-			return InternalServerError("database failed to create new user and identity: %+v", err)
-		}
-	} else {
-		user, err = a.queries.GetUser(r.Context(), identity.UserID)
-		if err != nil {
-			return InternalServerError("database failed to get user from existing identity")
-		}
+	if auth.IsUserBanned(user) {
+		return userBannedError(user)
 	}
 
-	token, err := a.issueToken(r.Context(), user)
+	token, err := a.signIn(r, user, AuthenticationMethodOAuth)
 	if err != nil {
-		return InternalServerError("failed to issue token")
+		return err
 	}
 
 	redirectTo := a.getExternalRedirectURL(r)
@@ -120,10 +105,13 @@ func (a *SurgeAPI) internalExternalProviderCallback(w http.ResponseWriter, r *ht
 	return nil
 }
 
-func (a *SurgeAPI) GetExternalProviderUrl(w http.ResponseWriter, r *http.Request) (string, error) {
+// GetExternalProviderUrl creates the authorization url of the provider in the query,
+// the identity is linked to the user of linkingTargetID instead of signing in if it is not empty
+func (a *SurgeAPI) GetExternalProviderUrl(w http.ResponseWriter, r *http.Request, linkingTargetID string) (string, error) {
 	query := r.URL.Query()
 
-	providerType := query.Get("provider")
+	// Provider names are case insensitive, identities are stored under the lowercase name like the id_token grant does
+	providerType := strings.ToLower(query.Get("provider"))
 	scopes := query.Get("scopes")
 	//codeChallenge := query.Get("code_challenge")
 	//codeChallengeMethod := query.Get("code_challenge_method")
@@ -135,10 +123,17 @@ func (a *SurgeAPI) GetExternalProviderUrl(w http.ResponseWriter, r *http.Request
 
 	claims := ExternalProviderClaims{
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: &jwt.NumericDate{Time: time.Now().Add(5 * time.Minute)},
+			ExpiresAt: &jwt.NumericDate{Time: time.Now().Add(externalStateExpiresAfter)},
 		},
-		Provider: providerType,
-		Referrer: GetRequestReferrer(r, a.config),
+		Provider:        providerType,
+		Referrer:        GetRequestReferrer(r, a.config),
+		LinkingTargetID: linkingTargetID,
+	}
+
+	if linkingTargetID != "" {
+		nonce := utilities.SecureToken()
+		claims.LinkingNonceHash = utilities.HashToken(nonce, []byte(a.config.JWT.Secret))
+		a.setCookieLinkingNonce(a.config, nonce, externalStateExpiresAfter, w)
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -200,7 +195,8 @@ func (a *SurgeAPI) getExternalRedirectURL(r *http.Request) string {
 	return config.ServiceURL
 }
 
-func (a *SurgeAPI) loadExternalStateToContext(ctx context.Context, state string) (context.Context, error) {
+func (a *SurgeAPI) loadExternalStateToContext(r *http.Request, state string) (context.Context, error) {
+	ctx := r.Context()
 	claims := ExternalProviderClaims{}
 	p := jwt.NewParser(jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}))
 	_, err := p.ParseWithClaims(state, &claims, func(token *jwt.Token) (interface{}, error) {
@@ -220,15 +216,167 @@ func (a *SurgeAPI) loadExternalStateToContext(ctx context.Context, state string)
 		if err != nil {
 			return nil, BadRequestError(ErrorCodeBadOAuth2State, "OAuth callback with invalid state (linking_target_id must be UUID)")
 		}
+		if !a.verifyLinkingNonce(r, claims.LinkingNonceHash) {
+			return nil, BadRequestError(ErrorCodeBadOAuth2State, "OAuth callback with invalid state (linking was started by another browser)")
+		}
 		u, err := a.queries.GetUser(ctx, linkingTargetUserID)
 		if err != nil {
-			if errors.Is(sql.ErrNoRows, err) {
+			if errors.Is(err, sql.ErrNoRows) {
 				return nil, UnprocessableEntityError(ErrorCodeUserNotFound, "Linking target user not found")
 			}
 			return nil, InternalServerError("Database error loading user: %+v", err)
 		}
+		// The user may have been banned or deleted since linking was started
+		if u.DeletedAt.Valid {
+			return nil, UnprocessableEntityError(ErrorCodeUserNotFound, "Linking target user not found")
+		}
+		if auth.IsUserBanned(u) {
+			return nil, userBannedError(u)
+		}
 		ctx = context.WithValue(ctx, contextTargetUserKey, u)
 	}
 	ctx = context.WithValue(ctx, contextExternalProviderTypeKey, claims.Provider)
 	return context.WithValue(ctx, contextSignatureKey, state), nil
 }
+
+// verifyLinkingNonce checks the nonce cookie of the browser matches the nonce linking was started with
+func (a *SurgeAPI) verifyLinkingNonce(r *http.Request, nonceHash string) bool {
+	cookie, err := r.Cookie(a.config.Cookie.Key + "-" + linkingNonceCookieName)
+	if err != nil || cookie.Value == "" || nonceHash == "" {
+		return false
+	}
+
+	return hmac.Equal([]byte(utilities.HashToken(cookie.Value, []byte(a.config.JWT.Secret))), []byte(nonceHash))
+}
+
+// selectPrimaryEmail sets the email claims to the primary email of the user data, or the last one if none is primary
+func selectPrimaryEmail(userData *provider.UserData) {
+	// Reset
+	userData.Claims.EmailVerified = false
+	for _, email := range userData.Emails {
+		userData.Claims.Email = email.Email
+		userData.Claims.EmailVerified = email.Verified
+
+		if email.Primary {
+			break
+		}
+	}
+}
+
+// findOrCreateExternalUser resolves the user of the identity the provider reported,
+// linking it to the user being linked to or to an existing user with the same email, or creating a new user
+func (a *SurgeAPI) findOrCreateExternalUser(r *http.Request, providerType string, userData *provider.UserData) (*schema.AuthUser, error) {
+	options := auth.CreateUserAndIdentityOptions{
+		Provider:          providerType,
+		ProviderAccountID: userData.Claims.Subject,
+		ProviderData:      *userData,
+	}
+
+	identity, err := a.queries.GetIdentity(r.Context(), schema.GetIdentityParams{
+		Provider:   providerType,
+		ProviderID: userData.Claims.Subject,
+	})
+	if targetUser := getTargetUser(r.Context()); targetUser != nil {
+		return a.linkExternalIdentity(r, targetUser, identity, err, options)
+	}
+	if err == nil {
+		return a.signInExternalIdentity(r, identity, userData)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, InternalServerError("database failed to find existing identity: %+v", err)
+	}
+
+	if a.config.Auth.AutoLinkSameEmail && userData.Claims.Email != "" {
+		existing, err := a.queries.GetUserByEmail(r.Context(), userData.Claims.Email)
+		if err == nil {
+			return a.autoLinkExternalIdentity(r, existing, userData, options)
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, InternalServerError("database failed to find user with same email: %+v", err)
+		}
+	}
+
+	user, _, err := auth.CreateUserAndIdentity(a.queries, r.Context(), options)
+	if err != nil {
+		return nil, InternalServerError("database failed to create new user and identity: %+v", err)
+	}
+
+	// Emails the provider didn't verify are stored unconfirmed, and never confirmed automatically
+	if user.Email.Valid && !user.EmailConfirmedAt.Valid {
+		if err := a.sendConfirmation(r, a.queries, user); err != nil {
+			return nil, InternalServerError("failed to send confirmation mail: %+v", err)
+		}
+	}
+
+	return user, nil
+}
+
+// signInExternalIdentity returns the user of the existing identity, storing the latest data of the provider
+// and syncing the profile of the user according to the configured policy
+func (a *SurgeAPI) signInExternalIdentity(r *http.Request, identity *schema.AuthIdentity, userData *provider.UserData) (*schema.AuthUser, error) {
+	user, err := a.queries.GetUser(r.Context(), identity.UserID)
+	if err != nil {
+		return nil, InternalServerError("database failed to get user from existing identity")
+	}
+
+	providerData, err := json.Marshal(userData)
+	if err != nil {
+		return nil, InternalServerError("failed to marshal provider data: %+v", err)
+	}
+
+	_, err = a.queries.UpdateIdentity(r.Context(), schema.UpdateIdentityParams{
+		ID:           identity.ID,
+		ProviderData: pqtype.NullRawMessage{RawMessage: providerData, Valid: true},
+		LastSignIn:   storage.NewTime(time.Now()),
+	})
+	if err != nil {
+		return nil, InternalServerError("database failed to update identity: %+v", err)
+	}
+
+	user, err = auth.SyncUserProfile(a.queries, r.Context(), user, a.config.External.ProfileSyncPolicy, userData.Claims)
+	if err != nil {
+		return nil, InternalServerError("database failed to sync user profile: %+v", err)
+	}
+
+	return user, nil
+}
+
+// autoLinkExternalIdentity attaches the identity to the existing user with the same email.
+// Both the provider and the existing user have to prove owning the email, otherwise whoever
+// registered the email first could take over the account of the other.
+func (a *SurgeAPI) autoLinkExternalIdentity(r *http.Request, existing *schema.AuthUser, userData *provider.UserData, options auth.CreateUserAndIdentityOptions) (*schema.AuthUser, error) {
+	if !userData.Claims.EmailVerified {
+		return nil, UnprocessableEntityError(ErrorCodeProviderEmailNotVerified, "a user with the email exists but the provider did not verify the email, sign in and link the identity instead")
+	}
+	// Users created with an email their provider didn't verify are unconfirmed even if emails are confirmed automatically
+	if !existing.EmailConfirmedAt.Valid {
+		return nil, UnprocessableEntityError(ErrorCodeEmailNotConfirmed, "a user with the email exists but has not confirmed the email, sign in and link the identity instead")
+	}
+
+	if _, err := auth.CreateIdentity(a.queries, r.Context(), existing, options); err != nil {
+		return nil, InternalServerError("database failed to link identity: %+v", err)
+	}
+
+	return existing, nil
+}
+
+// linkExternalIdentity attaches the identity of the provider to the target user,
+// identityErr is the error of looking up whether the identity already exists
+func (a *SurgeAPI) linkExternalIdentity(r *http.Request, targetUser *schema.AuthUser, identity *schema.AuthIdentity, identityErr error, options auth.CreateUserAndIdentityOptions) (*schema.AuthUser, error) {
+	if identityErr == nil {
+		if identity.UserID != targetUser.ID {
+			return nil, UnprocessableEntityError(ErrorCodeIdentityAlreadyExists, "identity is already linked to another user")
+		}
+		// Linking an identity already linked to the user signs in as usual
+		return targetUser, nil
+	}
+	if !errors.Is(identityErr, sql.ErrNoRows) {
+		return nil, InternalServerError("database failed to find existing identity: %+v", identityErr)
+	}
+
+	if _, err := auth.CreateIdentity(a.queries, r.Context(), targetUser, options); err != nil {
+		return nil, InternalServerError("database failed to link identity: %+v", err)
+	}
+
+	return targetUser, nil
+}
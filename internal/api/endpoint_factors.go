@@ -0,0 +1,461 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"image/png"
+	"net/http"
+	"surge/internal/auth"
+	"surge/internal/schema"
+	"surge/internal/storage"
+	"surge/internal/utilities"
+	"time"
+)
+
+// qrCodeSize is the width and height of enrollment QR codes in pixels
+const qrCodeSize = 256
+
+// EndpointFactors lists MFA factors of the logged in user
+func (a *SurgeAPI) EndpointFactors(w http.ResponseWriter, r *http.Request) error {
+	claims := getClaims(r.Context())
+	if claims == nil {
+		return InternalServerError("failed to read claims")
+	}
+
+	userId, err := claims.GetSubjectUUID()
+	if err != nil {
+		return BadRequestError(ErrorCodeBadJWT, "token subject is not a uuid")
+	}
+
+	factors, err := a.queries.ListFactorsOfUser(r.Context(), userId)
+	if err != nil {
+		return NewHTTPError(http.StatusInternalServerError, ErrorCodeDatabaseFailure, "unexpected database failure")
+	}
+
+	response := make([]*FactorResponse, len(factors))
+	for i, factor := range factors {
+		response[i] = NewFactorResponse(factor)
+	}
+
+	return writeResponseJSON(w, http.StatusOK, response)
+}
+
+// EndpointEnrollFactor enrolls an unverified MFA factor for the logged in user, which is verified by challenging it.
+// Users who already have a verified factor have to be signed in with a second factor.
+func (a *SurgeAPI) EndpointEnrollFactor(w http.ResponseWriter, r *http.Request) error {
+	claims := getClaims(r.Context())
+	if claims == nil {
+		return InternalServerError("failed to read claims")
+	}
+
+	userId, err := claims.GetSubjectUUID()
+	if err != nil {
+		return BadRequestError(ErrorCodeBadJWT, "token subject is not a uuid")
+	}
+
+	body, err := utilities.GetBodyJson[EnrollFactorRequest](r)
+	if err != nil {
+		return BadRequestError(ErrorCodeInvalidJSON, "failed to parse request body: %+v", err)
+	}
+
+	switch body.FactorType {
+	case auth.FactorTypeTOTP:
+	case auth.FactorTypeWebAuthn:
+		if a.webAuthn == nil {
+			return UnprocessableEntityError(ErrorCodeWebAuthnDisabled, "webauthn is not configured")
+		}
+	default:
+		return BadRequestError(ErrorCodeInvalidFactorType, "invalid factor type '%s'", body.FactorType)
+	}
+
+	var response *EnrollFactorResponse
+	err = a.Transaction(r.Context(), func(tx *sql.Tx, queries *schema.Queries) error {
+		user, err := getSubjectUser(r.Context(), queries, userId)
+		if err != nil {
+			return err
+		}
+
+		factors, err := queries.ListFactorsOfUser(r.Context(), user.ID)
+		if err != nil {
+			return err
+		}
+		if len(factors) >= a.config.MFA.MaxEnrolledFactors {
+			return UnprocessableEntityError(ErrorCodeTooManyFactors, "maximum of %d factors are enrolled", a.config.MFA.MaxEnrolledFactors)
+		}
+		for _, factor := range factors {
+			if factor.Status == auth.FactorStatusVerified && claims.AAL != AAL2 {
+				return ForbiddenError(ErrorCodeInsufficientAssurance, "session has to be verified with a second factor")
+			}
+		}
+
+		if body.FactorType == auth.FactorTypeWebAuthn {
+			factor, err := auth.EnrollWebAuthnFactor(queries, r.Context(), user, body.FriendlyName)
+			if err != nil {
+				return err
+			}
+
+			response = &EnrollFactorResponse{FactorResponse: NewFactorResponse(factor)}
+			return nil
+		}
+
+		factor, key, err := auth.EnrollTOTPFactor(queries, r.Context(), user, auth.EnrollTOTPFactorOptions{
+			FriendlyName: body.FriendlyName,
+			Issuer:       a.config.MFA.Issuer,
+			AccountName:  getTOTPAccountName(user),
+		})
+		if err != nil {
+			return err
+		}
+
+		image, err := key.Image(qrCodeSize, qrCodeSize)
+		if err != nil {
+			return err
+		}
+		var buffer bytes.Buffer
+		if err := png.Encode(&buffer, image); err != nil {
+			return err
+		}
+
+		response = &EnrollFactorResponse{
+			FactorResponse: NewFactorResponse(factor),
+			TOTP: &TOTPEnrollmentResponse{
+				Secret: key.Secret(),
+				URI:    key.URL(),
+				QRCode: "data:image/png;base64," + base64.StdEncoding.EncodeToString(buffer.Bytes()),
+			},
+		}
+		return nil
+	})
+	if err != nil {
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) {
+			return httpErr
+		}
+		return InternalServerError("failed to enroll factor")
+	}
+
+	return writeResponseJSON(w, http.StatusOK, response)
+}
+
+// EndpointDeleteFactor unenrolls the MFA factor of the logged in user.
+// Verified factors can only be removed from sessions verified with a second factor.
+func (a *SurgeAPI) EndpointDeleteFactor(w http.ResponseWriter, r *http.Request) error {
+	claims := getClaims(r.Context())
+	if claims == nil {
+		return InternalServerError("failed to read claims")
+	}
+
+	userId, err := claims.GetSubjectUUID()
+	if err != nil {
+		return BadRequestError(ErrorCodeBadJWT, "token subject is not a uuid")
+	}
+
+	factorId, err := uuid.Parse(chi.URLParam(r, "factor_id"))
+	if err != nil {
+		return BadRequestError(ErrorCodeInvalidField, "factor id is not a uuid")
+	}
+
+	factor, err := a.queries.GetFactor(r.Context(), factorId)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return NewHTTPError(http.StatusInternalServerError, ErrorCodeDatabaseFailure, "unexpected database failure")
+	}
+	if err != nil || factor.UserID != userId {
+		return NotFoundError(ErrorCodeFactorNotFound, "factor not found")
+	}
+
+	if factor.Status == auth.FactorStatusVerified && claims.AAL != AAL2 {
+		return ForbiddenError(ErrorCodeInsufficientAssurance, "session has to be verified with a second factor")
+	}
+
+	if err := a.queries.DeleteFactor(r.Context(), factor.ID); err != nil {
+		return NewHTTPError(http.StatusInternalServerError, ErrorCodeDatabaseFailure, "unexpected database failure")
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// EndpointChallengeFactor creates a challenge for the factor, to be verified with EndpointVerifyFactor
+func (a *SurgeAPI) EndpointChallengeFactor(w http.ResponseWriter, r *http.Request) error {
+	body, err := utilities.GetBodyJson[ChallengeFactorRequest](r)
+	if err != nil {
+		return BadRequestError(ErrorCodeInvalidJSON, "failed to parse request body: %+v", err)
+	}
+
+	var response *ChallengeResponse
+	err = a.Transaction(r.Context(), func(tx *sql.Tx, queries *schema.Queries) error {
+		subject, err := a.getMFASubject(r, queries, body.MFAToken)
+		if err != nil {
+			return err
+		}
+
+		factor, err := a.getFactorOfSubject(r, queries, subject)
+		if err != nil {
+			return err
+		}
+
+		var options interface{}
+		var sessionData *webauthn.SessionData
+		if factor.FactorType == auth.FactorTypeWebAuthn {
+			if a.webAuthn == nil {
+				return UnprocessableEntityError(ErrorCodeWebAuthnDisabled, "webauthn is not configured")
+			}
+
+			options, sessionData, err = auth.BeginWebAuthnFactorChallenge(queries, r.Context(), a.webAuthn, subject.user, factor)
+			if err != nil {
+				return err
+			}
+		}
+
+		challenge, err := auth.CreateChallenge(queries, r.Context(), factor, utilities.GetIPAddress(r), sessionData)
+		if err != nil {
+			return err
+		}
+
+		expiresAt := challenge.CreatedAt.Add(time.Second * time.Duration(a.config.MFA.ChallengeExpiresAfter))
+		response = &ChallengeResponse{
+			ID:        challenge.ID,
+			ExpiresAt: expiresAt.Unix(),
+			WebAuthn:  options,
+		}
+		return nil
+	})
+	if err != nil {
+		return mfaError(err)
+	}
+
+	return writeResponseJSON(w, http.StatusOK, response)
+}
+
+// EndpointVerifyFactor verifies the challenge with a code or credential and responds with tokens of a session verified with a second factor.
+// Signed in users raise their current session, users signing in get a new session.
+func (a *SurgeAPI) EndpointVerifyFactor(w http.ResponseWriter, r *http.Request) error {
+	body, err := utilities.GetBodyJson[VerifyFactorRequest](r)
+	if err != nil {
+		return BadRequestError(ErrorCodeInvalidJSON, "failed to parse request body: %+v", err)
+	}
+
+	var response *AccessTokenResponse
+	var wrongCodeErr error
+	err = a.Transaction(r.Context(), func(tx *sql.Tx, queries *schema.Queries) error {
+		subject, err := a.getMFASubject(r, queries, body.MFAToken)
+		if err != nil {
+			return err
+		}
+
+		factor, err := a.getFactorOfSubject(r, queries, subject)
+		if err != nil {
+			return err
+		}
+
+		var method AuthenticationMethod
+		switch factor.FactorType {
+		case auth.FactorTypeWebAuthn:
+			if a.webAuthn == nil {
+				return UnprocessableEntityError(ErrorCodeWebAuthnDisabled, "webauthn is not configured")
+			}
+			if len(body.Credential) == 0 {
+				return BadRequestError(ErrorCodeMissingField, "credential is empty or missing")
+			}
+
+			method = AuthenticationMethodWebAuthn
+			_, err = auth.VerifyWebAuthnChallenge(queries, r.Context(), a.config, a.webAuthn, subject.user, factor, body.ChallengeID, body.Credential)
+		default:
+			if body.Code == "" {
+				return BadRequestError(ErrorCodeMissingField, "code is empty or missing")
+			}
+
+			method = AuthenticationMethodTOTP
+			_, err = auth.VerifyChallenge(queries, r.Context(), a.config, factor, body.ChallengeID, body.Code)
+			if errors.Is(err, auth.ErrWrongCode) {
+				// Committing counts the wrong code towards invalidating the challenge and locking the factor
+				wrongCodeErr = err
+				return nil
+			}
+		}
+		if err != nil {
+			return err
+		}
+
+		session, err := a.completeMFASubject(r, queries, subject, body.MFAToken)
+		if err != nil {
+			return err
+		}
+
+		session, err = a.addSessionAuthentication(r.Context(), queries, session, method, AAL2)
+		if err != nil {
+			return err
+		}
+
+		response, err = a.issueToken(r.Context(), queries, subject.user, session, nil)
+		return err
+	})
+	if err == nil {
+		err = wrongCodeErr
+	}
+	if err != nil {
+		return mfaError(err)
+	}
+
+	return writeResponseJSON(w, http.StatusOK, response)
+}
+
+// mfaSubject is who a factor is challenged for, either a signed in session or a user signing in with an mfa token
+type mfaSubject struct {
+	user *schema.AuthUser
+	// session is nil while signing in
+	session *schema.AuthSession
+	// method is the first factor used to sign in, only while signing in
+	method AuthenticationMethod
+}
+
+// getMFASubject resolves the subject from the mfa token if provided, otherwise from the access token
+func (a *SurgeAPI) getMFASubject(r *http.Request, queries *schema.Queries, mfaToken *string) (*mfaSubject, error) {
+	if mfaToken != nil {
+		oneTimeToken, err := auth.FindOneTimeToken(queries, r.Context(), a.config, auth.OneTimeTokenMFA, *mfaToken)
+		if err != nil {
+			return nil, err
+		}
+
+		user, err := queries.GetUser(r.Context(), oneTimeToken.UserID)
+		if err != nil {
+			return nil, err
+		}
+
+		return &mfaSubject{user: user, method: oneTimeToken.RelatesTo}, nil
+	}
+
+	claims := getClaims(r.Context())
+	if claims == nil {
+		return nil, UnauthorizedError(ErrorCodeNoAuthorization, "either mfa_token or a Bearer token is required")
+	}
+
+	userId, err := claims.GetSubjectUUID()
+	if err != nil {
+		return nil, BadRequestError(ErrorCodeBadJWT, "token subject is not a uuid")
+	}
+	sessionId, err := claims.GetSessionUUID()
+	if err != nil {
+		return nil, BadRequestError(ErrorCodeBadJWT, "token session is not a uuid")
+	}
+
+	user, err := getSubjectUser(r.Context(), queries, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := queries.GetSession(r.Context(), sessionId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ForbiddenError(ErrorCodeSessionNotFound, "session does not exist")
+		}
+		return nil, err
+	}
+
+	return &mfaSubject{user: user, session: session}, nil
+}
+
+// getFactorOfSubject reads the factor in the url, only signed in users can use factors which are not verified yet
+func (a *SurgeAPI) getFactorOfSubject(r *http.Request, queries *schema.Queries, subject *mfaSubject) (*schema.AuthMfaFactor, error) {
+	factorId, err := uuid.Parse(chi.URLParam(r, "factor_id"))
+	if err != nil {
+		return nil, BadRequestError(ErrorCodeInvalidField, "factor id is not a uuid")
+	}
+
+	factor, err := queries.GetFactor(r.Context(), factorId)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if err != nil || factor.UserID != subject.user.ID {
+		return nil, NotFoundError(ErrorCodeFactorNotFound, "factor not found")
+	}
+
+	if subject.session == nil && factor.Status != auth.FactorStatusVerified {
+		return nil, UnprocessableEntityError(ErrorCodeFactorNotVerified, "factor is not verified")
+	}
+
+	return factor, nil
+}
+
+// completeMFASubject returns the session of the subject, consuming the mfa token to create one while signing in
+func (a *SurgeAPI) completeMFASubject(r *http.Request, queries *schema.Queries, subject *mfaSubject, mfaToken *string) (*schema.AuthSession, error) {
+	if subject.session != nil {
+		return subject.session, nil
+	}
+
+	if _, err := auth.ConsumeOneTimeToken(queries, r.Context(), a.config, auth.OneTimeTokenMFA, *mfaToken); err != nil {
+		return nil, err
+	}
+
+	return a.createSession(r, queries, subject.user, subject.method)
+}
+
+// createMFARequiredResponse hands out an mfa token to complete signing in of the user authenticated by method with a second factor
+func (a *SurgeAPI) createMFARequiredResponse(r *http.Request, user *schema.AuthUser, method AuthenticationMethod, factors []*schema.AuthMfaFactor) (*MFARequiredResponse, error) {
+	expiresAfter := time.Second * time.Duration(a.config.MFA.TokenExpiresAfter)
+
+	token, err := auth.CreateOneTimeToken(a.queries, r.Context(), a.config, user, auth.CreateOneTimeTokenOptions{
+		Type:         auth.OneTimeTokenMFA,
+		RelatesTo:    method,
+		ExpiresAfter: expiresAfter,
+	})
+	if err != nil {
+		return nil, InternalServerError("failed to create mfa token")
+	}
+
+	response := &MFARequiredResponse{
+		MFARequired: true,
+		MFAToken:    token,
+		ExpiresAt:   time.Now().Add(expiresAfter).Unix(),
+		Factors:     make([]*FactorResponse, len(factors)),
+	}
+	for i, factor := range factors {
+		response.Factors[i] = NewFactorResponse(factor)
+	}
+
+	return response, nil
+}
+
+// getTOTPAccountName picks what identifies the user in authenticator apps
+func getTOTPAccountName(user *schema.AuthUser) string {
+	if user.Email.Valid {
+		return user.Email.String
+	}
+	if phone := storage.InterfaceToStringPointer(user.Phone); phone != nil {
+		return *phone
+	}
+	if user.Username.Valid {
+		return user.Username.String
+	}
+	return user.ID.String()
+}
+
+// mfaError converts errors of challenging and verifying factors to HTTPError
+func mfaError(err error) error {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr
+	}
+
+	switch {
+	case errors.Is(err, auth.ErrTokenNotFound), errors.Is(err, auth.ErrTokenExpired):
+		return UnauthorizedError(ErrorCodeInvalidCredentials, "mfa token is invalid or has expired")
+	case errors.Is(err, auth.ErrChallengeNotFound):
+		return NotFoundError(ErrorCodeChallengeNotFound, "challenge not found")
+	case errors.Is(err, auth.ErrChallengeExpired):
+		return UnprocessableEntityError(ErrorCodeChallengeExpired, "challenge has expired")
+	case errors.Is(err, auth.ErrWrongCode):
+		return UnprocessableEntityError(ErrorCodeMFAVerificationFailed, "invalid code")
+	case errors.Is(err, auth.ErrFactorLocked):
+		return TooManyRequestsError(ErrorCodeFactorLocked, "factor is locked after too many invalid codes, try again later")
+	case errors.Is(err, auth.ErrWebAuthnFailed):
+		return UnprocessableEntityError(ErrorCodeMFAVerificationFailed, "invalid credential: %v", err)
+	default:
+		return NewHTTPError(http.StatusInternalServerError, ErrorCodeDatabaseFailure, "unexpected database failure")
+	}
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"net/http"
+	"surge/internal/schema"
+	"surge/internal/storage"
+)
+
+// EndpointIdentities lists external identities linked to the logged in user
+func (a *SurgeAPI) EndpointIdentities(w http.ResponseWriter, r *http.Request) error {
+	claims := getClaims(r.Context())
+	if claims == nil {
+		return InternalServerError("failed to read claims")
+	}
+
+	userId, err := claims.GetSubjectUUID()
+	if err != nil {
+		return BadRequestError(ErrorCodeBadJWT, "token subject is not a uuid")
+	}
+
+	identities, err := a.queries.GetIdentitiesByUser(r.Context(), userId)
+	if err != nil {
+		return InternalServerError("database failed to list identities: %+v", err)
+	}
+
+	response := make([]*IdentityResponse, 0, len(identities))
+	for _, identity := range identities {
+		response = append(response, NewIdentityResponse(identity))
+	}
+
+	return writeResponseJSON(w, http.StatusOK, response)
+}
+
+// EndpointLinkIdentity returns the url of the provider in the query, signing in there links the identity to the logged in user.
+// The response sets a cookie the callback is checked against, so it has to be requested with credentials from the same browser.
+func (a *SurgeAPI) EndpointLinkIdentity(w http.ResponseWriter, r *http.Request) error {
+	claims := getClaims(r.Context())
+	if claims == nil {
+		return InternalServerError("failed to read claims")
+	}
+
+	userId, err := claims.GetSubjectUUID()
+	if err != nil {
+		return BadRequestError(ErrorCodeBadJWT, "token subject is not a uuid")
+	}
+
+	targetUrl, err := a.GetExternalProviderUrl(w, r, userId.String())
+	if err != nil {
+		return err
+	}
+
+	return writeResponseJSON(w, http.StatusOK, &LinkIdentityResponse{URL: targetUrl})
+}
+
+// EndpointDeleteIdentity unlinks an identity of the logged in user, refusing to unlink the last way of signing in
+func (a *SurgeAPI) EndpointDeleteIdentity(w http.ResponseWriter, r *http.Request) error {
+	claims := getClaims(r.Context())
+	if claims == nil {
+		return InternalServerError("failed to read claims")
+	}
+
+	userId, err := claims.GetSubjectUUID()
+	if err != nil {
+		return BadRequestError(ErrorCodeBadJWT, "token subject is not a uuid")
+	}
+
+	identityId, err := uuid.Parse(chi.URLParam(r, "identity_id"))
+	if err != nil {
+		return BadRequestError(ErrorCodeInvalidField, "identity id is not a uuid")
+	}
+
+	err = a.Transaction(r.Context(), func(tx *sql.Tx, queries *schema.Queries) error {
+		identity, err := queries.GetIdentityById(r.Context(), identityId)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return NotFoundError(ErrorCodeIdentityNotFound, "failed to find identity")
+			}
+			return err
+		}
+
+		// Identities of other users are reported as missing to avoid leaking their existence
+		if identity.UserID != userId {
+			return NotFoundError(ErrorCodeIdentityNotFound, "failed to find identity")
+		}
+
+		user, err := getSubjectUser(r.Context(), queries, userId)
+		if err != nil {
+			return err
+		}
+
+		identities, err := queries.GetIdentitiesByUser(r.Context(), user.ID)
+		if err != nil {
+			return err
+		}
+
+		if len(identities) < 2 {
+			canSignIn, err := a.canSignInWithoutIdentities(r, queries, user)
+			if err != nil {
+				return err
+			}
+			if !canSignIn {
+				return UnprocessableEntityError(ErrorCodeLastSignInMethod, "identity is the last way of signing in")
+			}
+		}
+
+		return queries.DeleteIdentity(r.Context(), identity.ID)
+	})
+	if err != nil {
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) {
+			return httpErr
+		}
+		return InternalServerError("database failed to delete identity: %+v", err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// canSignInWithoutIdentities reports whether the user can sign in with credentials, one-time passwords or passkeys
+func (a *SurgeAPI) canSignInWithoutIdentities(r *http.Request, queries *schema.Queries, user *schema.AuthUser) (bool, error) {
+	config := a.config.Auth
+
+	hasEmail := user.Email.Valid && !config.DisableEmailAuth
+	hasPhone := storage.InterfaceToStringPointer(user.Phone) != nil && !config.DisablePhoneAuth
+	hasUsername := user.Username.Valid && !config.DisableUsernameAuth
+
+	if user.EncryptedPassword.Valid && (hasEmail || hasPhone || hasUsername) {
+		return true, nil
+	}
+	if !config.DisableOTPAuth && (hasEmail || hasPhone) {
+		return true, nil
+	}
+
+	if a.webAuthn != nil {
+		credentials, err := queries.ListWebAuthnCredentialsOfUser(r.Context(), user.ID)
+		if err != nil {
+			return false, err
+		}
+		if len(credentials) > 0 {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
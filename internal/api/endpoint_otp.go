@@ -0,0 +1,99 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"surge/internal/auth"
+	"surge/internal/schema"
+	"surge/internal/utilities"
+)
+
+// EndpointOTP mails a magic link or a one-time code, or texts a one-time code to sign in without password,
+// responding the same whether the email or phone exists or not
+func (a *SurgeAPI) EndpointOTP(w http.ResponseWriter, r *http.Request) error {
+	if a.config.Auth.DisableOTPAuth {
+		return UnprocessableEntityError(ErrorCodeDisabledGrantType, "one-time password authentication is disabled")
+	}
+
+	body, err := utilities.GetBodyJson[OTPRequest](r)
+	if err != nil {
+		return BadRequestError(ErrorCodeInvalidJSON, "failed to parse request body: %+v", err)
+	}
+
+	if utilities.CountNotNil([]*string{body.Email, body.Phone}) != 1 {
+		return BadRequestError(ErrorCodeMissingField, "either email or phone is required")
+	}
+
+	var otpType OTPType
+	if body.Email != nil {
+		if a.config.Auth.DisableEmailAuth {
+			return UnprocessableEntityError(ErrorCodeDisabledGrantType, "email authentication is disabled")
+		}
+		otpType = utilities.StringDefault(body.Type, OTPTypeMagicLink)
+	} else {
+		if a.config.Auth.DisablePhoneAuth {
+			return UnprocessableEntityError(ErrorCodeDisabledGrantType, "phone authentication is disabled")
+		}
+		otpType = utilities.StringDefault(body.Type, OTPTypeCode)
+		if otpType != OTPTypeCode {
+			return BadRequestError(ErrorCodeInvalidField, "phone only supports otp type '%s'", OTPTypeCode)
+		}
+	}
+
+	if otpType != OTPTypeMagicLink && otpType != OTPTypeCode {
+		return BadRequestError(ErrorCodeInvalidField, "invalid otp type '%s'", otpType)
+	}
+
+	createUser := a.config.Auth.OTPCreateUser && (body.CreateUser == nil || *body.CreateUser)
+
+	err = a.Transaction(r.Context(), func(tx *sql.Tx, queries *schema.Queries) error {
+		var user *schema.AuthUser
+		if body.Email != nil {
+			user, err = queries.GetUserByEmail(r.Context(), *body.Email)
+		} else {
+			user, err = queries.GetUserByPhone(r.Context(), *body.Phone)
+		}
+		if err != nil {
+			if !errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+			if !createUser {
+				return nil
+			}
+
+			user, err = auth.CreateUser(queries, r.Context(), a.config, auth.CreateUserOptions{
+				Email:           body.Email,
+				Phone:           body.Phone,
+				OneTimePassword: true,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		if body.Phone != nil {
+			err = a.sendPhoneOTP(r, queries, user)
+		} else if otpType == OTPTypeCode {
+			err = a.sendEmailOTP(r, queries, user)
+		} else {
+			err = a.sendMagicLink(r, queries, user)
+		}
+
+		// The previous magic link or code is still valid, responding the same doesn't reveal the user exists
+		if errors.Is(err, auth.ErrTokenTooSoon) {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) {
+			return httpErr
+		}
+		return userOptionsError(r, err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+	"net/http"
+	"surge/internal/auth"
+	"surge/internal/schema"
+	"surge/internal/utilities"
+)
+
+// EndpointRecover mails a password reset link, responding the same whether the email exists or not
+func (a *SurgeAPI) EndpointRecover(w http.ResponseWriter, r *http.Request) error {
+	body, err := utilities.GetBodyJson[RecoverRequest](r)
+	if err != nil {
+		return BadRequestError(ErrorCodeInvalidJSON, "failed to parse request body: %+v", err)
+	}
+
+	if body.Email == "" {
+		return BadRequestError(ErrorCodeMissingField, "email is empty or missing")
+	}
+
+	err = a.Transaction(r.Context(), func(tx *sql.Tx, queries *schema.Queries) error {
+		user, err := queries.GetUserByEmail(r.Context(), body.Email)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil
+			}
+			return err
+		}
+
+		err = a.sendRecovery(r, queries, user)
+
+		// The previous link is still valid, responding the same doesn't reveal the user exists
+		if errors.Is(err, auth.ErrTokenTooSoon) {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) {
+			return httpErr
+		}
+		return InternalServerError("failed to send recovery: %+v", err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// EndpointResetPassword redeems a recovery token to set a new password, signing out every existing session
+func (a *SurgeAPI) EndpointResetPassword(w http.ResponseWriter, r *http.Request) error {
+	body, err := utilities.GetBodyJson[ResetPasswordRequest](r)
+	if err != nil {
+		return BadRequestError(ErrorCodeInvalidJSON, "failed to parse request body: %+v", err)
+	}
+
+	if body.Token == "" {
+		return BadRequestError(ErrorCodeMissingField, "token is empty or missing")
+	}
+
+	var user *schema.AuthUser
+	err = a.Transaction(r.Context(), func(tx *sql.Tx, queries *schema.Queries) error {
+		oneTimeToken, err := auth.ConsumeOneTimeToken(queries, r.Context(), a.config, auth.OneTimeTokenRecovery, body.Token)
+		if err != nil {
+			return err
+		}
+
+		user, err = queries.GetUser(r.Context(), oneTimeToken.UserID)
+		if err != nil {
+			return err
+		}
+
+		// Email was changed after the recovery was sent
+		if user.Email.String != oneTimeToken.RelatesTo {
+			return auth.ErrTokenNotFound
+		}
+
+		user, err = auth.SetPassword(queries, r.Context(), user, body.NewPassword)
+		if err != nil {
+			return err
+		}
+
+		// Receiving the recovery mail proves ownership of the email
+		if !user.EmailConfirmedAt.Valid {
+			user, err = auth.SetEmailConfirmed(queries, r.Context(), user, true)
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := queries.RevokeRefreshTokensOfUser(r.Context(), uuid.NullUUID{UUID: user.ID, Valid: true}); err != nil {
+			return err
+		}
+		return queries.DeleteSessionsOfUser(r.Context(), user.ID)
+	})
+	if err != nil {
+		if errors.Is(err, auth.ErrTokenNotFound) || errors.Is(err, auth.ErrTokenExpired) {
+			return oneTimeTokenError(err)
+		}
+		return userOptionsError(r, err)
+	}
+
+	token, err := a.signIn(r, user, AuthenticationMethodRecovery)
+	if err != nil {
+		return err
+	}
+
+	return writeResponseJSON(w, http.StatusOK, token)
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"surge/internal/auth"
+	"surge/internal/schema"
+	"surge/internal/utilities"
+)
+
+// EndpointRecoveryCodes tells how many unused recovery codes the logged in user has left
+func (a *SurgeAPI) EndpointRecoveryCodes(w http.ResponseWriter, r *http.Request) error {
+	claims := getClaims(r.Context())
+	if claims == nil {
+		return InternalServerError("failed to read claims")
+	}
+
+	userId, err := claims.GetSubjectUUID()
+	if err != nil {
+		return BadRequestError(ErrorCodeBadJWT, "token subject is not a uuid")
+	}
+
+	remaining, err := a.queries.CountUnusedRecoveryCodesOfUser(r.Context(), userId)
+	if err != nil {
+		return NewHTTPError(http.StatusInternalServerError, ErrorCodeDatabaseFailure, "unexpected database failure")
+	}
+
+	return writeResponseJSON(w, http.StatusOK, &RecoveryCodesStatusResponse{Remaining: remaining})
+}
+
+// EndpointRegenerateRecoveryCodes replaces the recovery codes of the logged in user, invalidating the previous ones.
+// The session has to be verified with a second factor.
+func (a *SurgeAPI) EndpointRegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) error {
+	claims := getClaims(r.Context())
+	if claims == nil {
+		return InternalServerError("failed to read claims")
+	}
+
+	userId, err := claims.GetSubjectUUID()
+	if err != nil {
+		return BadRequestError(ErrorCodeBadJWT, "token subject is not a uuid")
+	}
+
+	if claims.AAL != AAL2 {
+		return ForbiddenError(ErrorCodeInsufficientAssurance, "session has to be verified with a second factor")
+	}
+
+	var codes []string
+	err = a.Transaction(r.Context(), func(tx *sql.Tx, queries *schema.Queries) error {
+		user, err := getSubjectUser(r.Context(), queries, userId)
+		if err != nil {
+			return err
+		}
+
+		codes, err = auth.GenerateRecoveryCodes(queries, r.Context(), a.config, user)
+		if err != nil {
+			return err
+		}
+
+		return auth.RecordAuditEvent(queries, r.Context(), user.ID, auth.AuditActionRecoveryCodesGenerated, utilities.GetIPAddress(r), map[string]interface{}{
+			"count": len(codes),
+		})
+	})
+	if err != nil {
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) {
+			return httpErr
+		}
+		return InternalServerError("failed to generate recovery codes")
+	}
+
+	return writeResponseJSON(w, http.StatusOK, &RecoveryCodesResponse{Codes: codes})
+}
+
+// EndpointVerifyRecoveryCode satisfies the second factor with a recovery code in place of a lost factor,
+// responding with tokens like EndpointVerifyFactor. The code can't be used again.
+func (a *SurgeAPI) EndpointVerifyRecoveryCode(w http.ResponseWriter, r *http.Request) error {
+	body, err := utilities.GetBodyJson[VerifyRecoveryCodeRequest](r)
+	if err != nil {
+		return BadRequestError(ErrorCodeInvalidJSON, "failed to parse request body: %+v", err)
+	}
+
+	if body.Code == "" {
+		return BadRequestError(ErrorCodeMissingField, "code is empty or missing")
+	}
+
+	var response *AccessTokenResponse
+	err = a.Transaction(r.Context(), func(tx *sql.Tx, queries *schema.Queries) error {
+		subject, err := a.getMFASubject(r, queries, body.MFAToken)
+		if err != nil {
+			return err
+		}
+
+		recoveryCode, err := auth.ConsumeRecoveryCode(queries, r.Context(), a.config, subject.user, body.Code)
+		if err != nil {
+			return err
+		}
+
+		if err := auth.RecordAuditEvent(queries, r.Context(), subject.user.ID, auth.AuditActionRecoveryCodeUsed, utilities.GetIPAddress(r), map[string]interface{}{
+			"recovery_code_id": recoveryCode.ID,
+		}); err != nil {
+			return err
+		}
+
+		session, err := a.completeMFASubject(r, queries, subject, body.MFAToken)
+		if err != nil {
+			return err
+		}
+
+		session, err = a.addSessionAuthentication(r.Context(), queries, session, AuthenticationMethodRecoveryCode, AAL2)
+		if err != nil {
+			return err
+		}
+
+		response, err = a.issueToken(r.Context(), queries, subject.user, session, nil)
+		return err
+	})
+	if err != nil {
+		return mfaError(err)
+	}
+
+	return writeResponseJSON(w, http.StatusOK, response)
+}
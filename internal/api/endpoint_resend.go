@@ -0,0 +1,58 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"surge/internal/auth"
+	"surge/internal/schema"
+	"surge/internal/utilities"
+)
+
+type ResendRequest struct {
+	Email string `json:"email"`
+}
+
+// EndpointResend sends the confirmation mail again, responding the same whether the email exists or not
+func (a *SurgeAPI) EndpointResend(w http.ResponseWriter, r *http.Request) error {
+	body, err := utilities.GetBodyJson[ResendRequest](r)
+	if err != nil {
+		return BadRequestError(ErrorCodeInvalidJSON, "failed to parse request body: %+v", err)
+	}
+
+	if body.Email == "" {
+		return BadRequestError(ErrorCodeMissingField, "email is empty or missing")
+	}
+
+	err = a.Transaction(r.Context(), func(tx *sql.Tx, queries *schema.Queries) error {
+		user, err := queries.GetUserByEmail(r.Context(), body.Email)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil
+			}
+			return err
+		}
+
+		if user.EmailConfirmedAt.Valid {
+			return nil
+		}
+
+		_, err = a.confirmOrSendConfirmation(r, queries, user)
+
+		// The previous link is still valid, responding the same doesn't reveal the user exists
+		if errors.Is(err, auth.ErrTokenTooSoon) {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) {
+			return httpErr
+		}
+		return InternalServerError("failed to resend confirmation: %+v", err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"net/http"
+	"surge/internal/schema"
+)
+
+// EndpointSessions lists sessions of the logged in user
+func (a *SurgeAPI) EndpointSessions(w http.ResponseWriter, r *http.Request) error {
+	claims := getClaims(r.Context())
+	if claims == nil {
+		return InternalServerError("failed to read claims")
+	}
+
+	userId, err := claims.GetSubjectUUID()
+	if err != nil {
+		return BadRequestError(ErrorCodeBadJWT, "token subject is not a uuid")
+	}
+
+	sessions, err := a.queries.ListSessionsByUser(r.Context(), userId)
+	if err != nil {
+		return InternalServerError("database failed to list sessions: %+v", err)
+	}
+
+	response := make([]*SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, NewSessionResponse(session, claims.SessionID))
+	}
+
+	return writeResponseJSON(w, http.StatusOK, response)
+}
+
+// EndpointDeleteSession revokes a session of the logged in user with every refresh token issued within it
+func (a *SurgeAPI) EndpointDeleteSession(w http.ResponseWriter, r *http.Request) error {
+	claims := getClaims(r.Context())
+	if claims == nil {
+		return InternalServerError("failed to read claims")
+	}
+
+	userId, err := claims.GetSubjectUUID()
+	if err != nil {
+		return BadRequestError(ErrorCodeBadJWT, "token subject is not a uuid")
+	}
+
+	sessionId, err := uuid.Parse(chi.URLParam(r, "session_id"))
+	if err != nil {
+		return BadRequestError(ErrorCodeInvalidField, "session id is not a uuid")
+	}
+
+	err = a.Transaction(r.Context(), func(tx *sql.Tx, queries *schema.Queries) error {
+		session, err := queries.GetSession(r.Context(), sessionId)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return NotFoundError(ErrorCodeSessionNotFound, "failed to find session")
+			}
+			return err
+		}
+
+		// Sessions of other users are reported as missing to avoid leaking their existence
+		if session.UserID != userId {
+			return NotFoundError(ErrorCodeSessionNotFound, "failed to find session")
+		}
+
+		return queries.DeleteSession(r.Context(), session.ID)
+	})
+	if err != nil {
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) {
+			return httpErr
+		}
+		return InternalServerError("database failed to delete session: %+v", err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
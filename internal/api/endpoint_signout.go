@@ -20,20 +20,20 @@ func (a *SurgeAPI) EndpointSignOut(w http.ResponseWriter, r *http.Request) error
 	ctx := r.Context()
 	config := a.config
 
-	//scope := LogoutScopeGlobal
-	//
-	//if r.URL.Query() != nil {
-	//	switch r.URL.Query().Get("scope") {
-	//	//case "", "global":
-	//	//	scope = LogoutScopeGlobal
-	//	//case "local":
-	//	//	scope = LogoutScopeLocal
-	//	//case "others":
-	//	//	scope = LogoutScopeOthers
-	//	default:
-	//		return BadRequestError(ErrorCodeInvalidLogoutScope, fmt.Sprintf("Unsupported logout scope %q", r.URL.Query().Get("scope")))
-	//	}
-	//}
+	scope := LogoutScopeGlobal
+
+	if r.URL.Query() != nil {
+		switch r.URL.Query().Get("scope") {
+		case "", "global":
+			scope = LogoutScopeGlobal
+		case "local":
+			scope = LogoutScopeLocal
+		case "others":
+			scope = LogoutScopeOthers
+		default:
+			return BadRequestError(ErrorCodeInvalidLogoutScope, "Unsupported logout scope %q", r.URL.Query().Get("scope"))
+		}
+	}
 
 	c := getClaims(ctx)
 	userId, err := c.GetSubjectUUID()
@@ -41,14 +41,33 @@ func (a *SurgeAPI) EndpointSignOut(w http.ResponseWriter, r *http.Request) error
 		return err
 	}
 
+	var sessionId uuid.UUID
+	if scope != LogoutScopeGlobal {
+		sessionId, err = c.GetSessionUUID()
+		if err != nil {
+			return BadRequestError(ErrorCodeBadJWT, "token is not bound to a session, logout scope %q is unavailable", scope)
+		}
+	}
+
 	err = a.Transaction(ctx, func(tx *sql.Tx, queries *schema.Queries) error {
-		return queries.RevokeRefreshTokensOfUser(ctx, uuid.NullUUID{UUID: userId, Valid: true})
+		switch scope {
+		case LogoutScopeLocal:
+			// Refresh tokens of the session are removed along with it
+			return queries.DeleteSession(ctx, sessionId)
+		case LogoutScopeOthers:
+			return a.revokeOtherSessions(ctx, queries, userId, sessionId)
+		default:
+			return a.revokeAllSessions(ctx, queries, userId)
+		}
 	})
 	if err != nil {
 		return InternalServerError("Error logging out user: %+v", err)
 	}
 
-	a.clearCookieTokens(config, w)
+	// Only the current device loses its cookies when signing out other sessions
+	if scope != LogoutScopeOthers {
+		a.clearCookieTokens(config, w)
+	}
 	w.WriteHeader(http.StatusNoContent)
 
 	return nil
@@ -1,12 +1,11 @@
 package api
 
 import (
+	"database/sql"
 	"errors"
-	"fmt"
-	"github.com/go-playground/validator/v10"
-	"golang.org/x/crypto/bcrypt"
 	"net/http"
 	"surge/internal/auth"
+	"surge/internal/schema"
 	"surge/internal/utilities"
 )
 
@@ -16,65 +15,42 @@ func (a *SurgeAPI) EndpointSignUpWithCredentials(w http.ResponseWriter, r *http.
 		return err
 	}
 
-	var validationErrors validator.ValidationErrors
+	if body.Password == nil {
+		return BadRequestError(ErrorCodeMissingField, "password is empty or missing")
+	}
 
-	queries := a.queries
+	var createdUser *schema.AuthUser
+	err = a.Transaction(r.Context(), func(tx *sql.Tx, queries *schema.Queries) error {
+		createdUser, err = auth.CreateUser(queries, r.Context(), a.config, auth.CreateUserOptions{
+			Phone:    body.Phone,
+			Email:    body.Email,
+			Username: body.Username,
+			Password: body.Password,
+			Metadata: auth.UserMetadata{
+				Avatar:    body.Metadata.Avatar,
+				FirstName: body.Metadata.FirstName,
+				LastName:  body.Metadata.LastName,
+				Birthdate: body.Metadata.Birthdate,
+			},
+		})
+		if err != nil {
+			return err
+		}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(*body.Password), bcrypt.DefaultCost)
-	if err != nil {
-		return InternalServerError("failed to hash password")
-	}
-	hashedPasswordString := string(hashedPassword[:])
+		createdUser, err = a.confirmOrSendConfirmation(r, queries, createdUser)
+		if err != nil {
+			return err
+		}
 
-	createdUser, err := auth.CreateUser(queries, r.Context(), a.config, auth.CreateUserOptions{
-		Phone:    body.Phone,
-		Email:    body.Email,
-		Username: body.Username,
-		Password: &hashedPasswordString,
-		Metadata: auth.UserMetadata{
-			Avatar:    body.Metadata.Avatar,
-			FirstName: body.Metadata.FirstName,
-			LastName:  body.Metadata.LastName,
-			Birthdate: body.Metadata.Birthdate,
-		},
+		createdUser, err = a.confirmOrSendPhoneConfirmation(r, queries, createdUser)
+		return err
 	})
 	if err != nil {
-		switch {
-		case errors.Is(auth.ErrMissingField, err):
-		case errors.Is(auth.ErrRequiredUsername, err):
-		case errors.Is(auth.ErrRequiredEmail, err):
-			return BadRequestError(ErrorCodeMissingField, err.Error())
-		case errors.Is(auth.ErrInvalidEmail, err):
-		case errors.Is(auth.ErrInvalidUsername, err):
-		case errors.Is(auth.ErrInvalidPassword, err):
-			return BadRequestError(ErrorCodeInvalidField, err.Error())
-		case errors.Is(auth.ErrDuplicateEmail, err):
-		case errors.Is(auth.ErrDuplicateUsername, err):
-		case errors.Is(auth.ErrDuplicatePhone, err):
-			return ConflictError(err.Error())
-		case errors.Is(auth.ErrDatabaseJob, err):
-			return InternalServerError("failed to do database action")
-		case errors.As(err, &validationErrors):
-			httpErr := NewBuilder().
-				UseRequest(r).
-				SetStatus(http.StatusBadRequest).
-				SetErrorCode(ErrorCodeInvalidJSON).
-				SetDetails(utilities.Map(
-					validationErrors,
-					func(t validator.FieldError) any {
-						return map[string]any{
-							"tag":       t.Tag(),
-							"namespace": t.Namespace(),
-							"field":     t.Field(),
-							"error":     fmt.Sprintf("failed validation for field '%s' on the '%s' tag", t.Field(), t.Tag()),
-						}
-					},
-				)).
-				Build()
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) {
 			return httpErr
 		}
-
-		return InternalServerError("unknown error during creating user")
+		return userOptionsError(r, err)
 	}
 
 	return writeResponseJSON(w, http.StatusOK, NewUserResponse(createdUser))
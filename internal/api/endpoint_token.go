@@ -2,12 +2,18 @@ package api
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/subtle"
 	"database/sql"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 	"net/http"
+	"strings"
+	"surge/internal/api/provider"
 	"surge/internal/auth"
 	"surge/internal/conf"
 	"surge/internal/schema"
@@ -18,8 +24,15 @@ import (
 
 type AccessTokenClaims struct {
 	jwt.RegisteredClaims
-	Email    *string `json:"email"`
-	Username *string `json:"username"`
+	Email     *string `json:"email"`
+	Username  *string `json:"username"`
+	SessionID string  `json:"session_id"`
+
+	AAL AuthenticatorAssuranceLevel `json:"aal"`
+	AMR []AMREntry                  `json:"amr"`
+
+	// Role is never issued by Surge, JWTs signed with the configured keys carry it to access the admin api
+	Role string `json:"role,omitempty"`
 }
 
 func (c AccessTokenClaims) GetSubjectUUID() (uuid.UUID, error) {
@@ -30,16 +43,27 @@ func (c AccessTokenClaims) GetSubjectUUID() (uuid.UUID, error) {
 	return uuid.Parse(subject)
 }
 
+func (c AccessTokenClaims) GetSessionUUID() (uuid.UUID, error) {
+	return uuid.Parse(c.SessionID)
+}
+
+// refreshTokenLength is the amount of random bytes a refresh token is made of
+const refreshTokenLength = 32
+
 type TokenGrantType = string
 
 const (
 	TokenGrantTypeCredentials TokenGrantType = "credentials"
 	TokenGrantTypeRefresh     TokenGrantType = "refresh"
+	TokenGrantTypeOTP         TokenGrantType = "otp"
+	TokenGrantTypeWebAuthn    TokenGrantType = "webauthn"
+	TokenGrantTypeIDToken     TokenGrantType = "id_token"
 )
 
 type tokenCredentialsGrantTypeRequest struct {
 	Username *string `json:"username"`
 	Email    *string `json:"email"`
+	Phone    *string `json:"phone"`
 	Password *string `json:"password"`
 }
 
@@ -47,6 +71,26 @@ type tokenRefreshGrantTypeRequest struct {
 	RefreshToken string `json:"refresh_token"`
 }
 
+type tokenWebAuthnGrantTypeRequest struct {
+	ChallengeID uuid.UUID       `json:"challenge_id"`
+	Credential  json.RawMessage `json:"credential"`
+}
+
+type tokenIDTokenGrantTypeRequest struct {
+	Provider string `json:"provider"`
+	IDToken  string `json:"id_token"`
+	// AccessToken is checked against the at_hash claim of the id token if both are present
+	AccessToken string `json:"access_token"`
+	// Nonce is the raw nonce the id token was requested with, the token may hold it raw or as a SHA-256 hex digest
+	Nonce string `json:"nonce"`
+}
+
+type tokenOTPGrantTypeRequest struct {
+	Email *string `json:"email"`
+	Phone *string `json:"phone"`
+	Code  string  `json:"code"`
+}
+
 // EndpointToken endpoint used to log in a user and respond with accessToken
 func (a *SurgeAPI) EndpointToken(w http.ResponseWriter, r *http.Request) error {
 	grantType := r.URL.Query().Get("grant_type")
@@ -56,6 +100,12 @@ func (a *SurgeAPI) EndpointToken(w http.ResponseWriter, r *http.Request) error {
 		return a.tokenCredentialsGrantFlow(w, r)
 	case TokenGrantTypeRefresh:
 		return a.tokenRefreshGrantFlow(w, r)
+	case TokenGrantTypeOTP:
+		return a.tokenOTPGrantFlow(w, r)
+	case TokenGrantTypeWebAuthn:
+		return a.tokenWebAuthnGrantFlow(w, r)
+	case TokenGrantTypeIDToken:
+		return a.tokenIDTokenGrantFlow(w, r)
 	default:
 		return BadRequestError(ErrorCodeInvalidGrantType, "invalid grant type '%s'", grantType)
 	}
@@ -70,9 +120,13 @@ func (a *SurgeAPI) tokenCredentialsGrantFlow(w http.ResponseWriter, r *http.Requ
 		return err
 	}
 
-	// Email and Username field is provided at the same time
-	if utilities.CountNotNil([]*string{body.Email, body.Username}) != 1 {
-		return BadRequestError(ErrorCodeInvalidJSON, "email or username can't be provided at the same time")
+	// Email, Username and Phone field is provided at the same time
+	if utilities.CountNotNil([]*string{body.Email, body.Username, body.Phone}) != 1 {
+		return BadRequestError(ErrorCodeInvalidJSON, "exactly one of email, username or phone has to be provided")
+	}
+
+	if body.Password == nil {
+		return BadRequestError(ErrorCodeMissingField, "password is empty or missing")
 	}
 
 	var user *schema.AuthUser
@@ -91,6 +145,13 @@ func (a *SurgeAPI) tokenCredentialsGrantFlow(w http.ResponseWriter, r *http.Requ
 		}
 
 		user, err = a.queries.GetUserByUsername(r.Context(), *body.Username)
+	} else if body.Phone != nil {
+		// Abort if phone auth is disabled
+		if a.config.Auth.DisablePhoneAuth {
+			return UnprocessableEntityError(ErrorCodeDisabledGrantType, "phone authentication is disabled")
+		}
+
+		user, err = a.queries.GetUserByPhone(r.Context(), *body.Phone)
 	}
 
 	if err != nil {
@@ -104,7 +165,19 @@ func (a *SurgeAPI) tokenCredentialsGrantFlow(w http.ResponseWriter, r *http.Requ
 		return authorizationErr
 	}
 
-	token, err := a.issueToken(r.Context(), user)
+	if auth.IsUserBanned(user) {
+		return userBannedError(user)
+	}
+
+	if body.Phone != nil {
+		if !user.PhoneConfirmedAt.Valid && !a.config.Auth.AutoConfirmPhone {
+			return UnprocessableEntityError(ErrorCodePhoneNotConfirmed, "phone is not confirmed")
+		}
+	} else if user.Email.Valid && !user.EmailConfirmedAt.Valid && !a.config.Auth.AutoConfirmEmail {
+		return UnprocessableEntityError(ErrorCodeEmailNotConfirmed, "email is not confirmed")
+	}
+
+	token, err := a.signIn(r, user, AuthenticationMethodPassword)
 	if err != nil {
 		return err
 	}
@@ -113,6 +186,8 @@ func (a *SurgeAPI) tokenCredentialsGrantFlow(w http.ResponseWriter, r *http.Requ
 }
 
 func (a *SurgeAPI) tokenRefreshGrantFlow(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
 	body, err := utilities.GetBodyJson[tokenRefreshGrantTypeRequest](r)
 	if err != nil {
 		return err
@@ -122,45 +197,355 @@ func (a *SurgeAPI) tokenRefreshGrantFlow(w http.ResponseWriter, r *http.Request)
 		return BadRequestError(ErrorCodeInvalidField, "refresh_token is empty or missing")
 	}
 
-	refreshToken, err := a.queries.GetRefreshToken(r.Context(), body.RefreshToken)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return NotFoundError(ErrorCodeRefreshNotFoundToken, "failed to find refresh token")
+	var response *AccessTokenResponse
+	var reused bool
+
+	// Revoke and issue new refresh token within the same session
+	err = a.Transaction(ctx, func(tx *sql.Tx, queries *schema.Queries) error {
+		refreshTokenHash := a.hashRefreshToken(body.RefreshToken)
+		refreshToken, err := queries.GetRefreshToken(ctx, schema.GetRefreshTokenParams{
+			TokenHash: refreshTokenHash,
+			Token:     body.RefreshToken,
+		})
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return NotFoundError(ErrorCodeRefreshNotFoundToken, "failed to find refresh token")
+			}
+			return err
+		}
+
+		// Tokens stored as plaintext before hashing was introduced are hashed once they are used,
+		// they are not found anymore 30 days after being issued
+		if !refreshToken.Hashed {
+			err = queries.HashRefreshToken(ctx, schema.HashRefreshTokenParams{
+				ID:    refreshToken.ID,
+				Token: storage.NewString(refreshTokenHash),
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		if refreshToken.Revoked {
+			allowed, err := a.isRefreshTokenReuseAllowed(ctx, queries, refreshToken)
+			if err != nil {
+				return err
+			}
+
+			if !allowed {
+				// Revoked token was replayed, every token rotated from it is considered compromised.
+				// The transaction has to be committed, so the error is returned after it.
+				reused = true
+				return queries.RevokeRefreshTokenDescendants(ctx, sql.NullInt64{Int64: refreshToken.ID, Valid: true})
+			}
+		} else if err := queries.RevokeRefreshToken(ctx, refreshToken.ID); err != nil {
+			return err
+		}
+
+		user, err := queries.GetUser(ctx, refreshToken.UserID.UUID)
+		if err != nil {
+			return err
+		}
+
+		if auth.IsUserBanned(user) {
+			return userBannedError(user)
+		}
+		if user.DeletedAt.Valid {
+			return ForbiddenError(ErrorCodeUserNotFound, "user is deleted")
+		}
+
+		var session *schema.AuthSession
+		if refreshToken.SessionID.Valid {
+			session, err = queries.UpdateSessionRefreshedAt(ctx, refreshToken.SessionID.UUID)
+		} else {
+			// Refresh tokens issued before sessions existed are moved into a new session
+			session, err = a.createSession(r, queries, user, AuthenticationMethodTokenRefresh)
 		}
+		if err != nil {
+			return err
+		}
+
+		response, err = a.issueToken(ctx, queries, user, session, refreshToken)
+		return err
+	})
+	if err != nil {
 		return err
 	}
 
-	if refreshToken.Revoked {
+	if reused {
+		logrus.WithContext(ctx).Warnln("revoked refresh token was reused, revoked its descendants")
 		return ForbiddenError(ErrorCodeRefreshTokenRevoked, "refresh token was revoked")
 	}
 
-	user, err := a.queries.GetUser(r.Context(), refreshToken.UserID.UUID)
+	return writeResponseJSON(w, http.StatusOK, response)
+}
+
+// isRefreshTokenReuseAllowed reports whether a revoked refresh token was rotated recently enough
+// to be a concurrent refresh from the same client rather than a replay
+func (a *SurgeAPI) isRefreshTokenReuseAllowed(ctx context.Context, queries *schema.Queries, refreshToken *schema.AuthRefreshToken) (bool, error) {
+	reuseInterval := time.Second * time.Duration(a.config.JWT.RefreshTokenReuseInterval)
+	if time.Since(refreshToken.UpdatedAt) > reuseInterval {
+		return false, nil
+	}
+
+	// Tokens revoked without being rotated (e.g. by signing out) can never be reused
+	child, err := queries.GetLatestRefreshTokenChild(ctx, sql.NullInt64{Int64: refreshToken.ID, Valid: true})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return !child.Revoked, nil
+}
+
+// tokenOTPGrantFlow processes grant flow with email or phone and the one-time code sent to it
+func (a *SurgeAPI) tokenOTPGrantFlow(w http.ResponseWriter, r *http.Request) error {
+	authorizationErr := UnauthorizedError(ErrorCodeInvalidCredentials, "code is invalid or has expired")
+
+	if a.config.Auth.DisableOTPAuth {
+		return UnprocessableEntityError(ErrorCodeDisabledGrantType, "one-time password authentication is disabled")
+	}
+
+	body, err := utilities.GetBodyJson[tokenOTPGrantTypeRequest](r)
 	if err != nil {
 		return err
 	}
 
-	var response *AccessTokenResponse
+	if utilities.CountNotNil([]*string{body.Email, body.Phone}) != 1 || body.Code == "" {
+		return BadRequestError(ErrorCodeMissingField, "either email or phone, and code are required")
+	}
 
-	// Revoke and issue new refresh token
+	if body.Email != nil && a.config.Auth.DisableEmailAuth {
+		return UnprocessableEntityError(ErrorCodeDisabledGrantType, "email authentication is disabled")
+	}
+	if body.Phone != nil && a.config.Auth.DisablePhoneAuth {
+		return UnprocessableEntityError(ErrorCodeDisabledGrantType, "phone authentication is disabled")
+	}
+
+	var user *schema.AuthUser
+	var wrongCodeErr error
 	err = a.Transaction(r.Context(), func(tx *sql.Tx, queries *schema.Queries) error {
-		if err := queries.RevokeRefreshToken(r.Context(), refreshToken.ID); err != nil {
+		tokenType, recipient := auth.OneTimeTokenEmailOTP, body.Email
+		if body.Phone != nil {
+			tokenType, recipient = auth.OneTimeTokenPhoneOTP, body.Phone
+			user, err = queries.GetUserByPhone(r.Context(), *body.Phone)
+		} else {
+			user, err = queries.GetUserByEmail(r.Context(), *body.Email)
+		}
+		if err != nil {
+			return err
+		}
+
+		oneTimeToken, err := auth.ConsumeOneTimeTokenOfUser(queries, r.Context(), a.config, user, tokenType, body.Code)
+		if errors.Is(err, auth.ErrWrongCode) {
+			// Committing counts the wrong code towards deleting the code
+			wrongCodeErr = err
+			return nil
+		}
+		if err != nil {
 			return err
 		}
 
-		response, err = a.issueToken(r.Context(), user)
+		// Email or phone was changed after the code was sent
+		if *recipient != oneTimeToken.RelatesTo {
+			return auth.ErrTokenNotFound
+		}
+
+		// Receiving the code proves ownership of the email or phone
+		if body.Phone != nil {
+			if !user.PhoneConfirmedAt.Valid {
+				user, err = auth.SetPhoneConfirmed(queries, r.Context(), user, true)
+			}
+		} else if !user.EmailConfirmedAt.Valid {
+			user, err = auth.SetEmailConfirmed(queries, r.Context(), user, true)
+		}
 		return err
 	})
+	if err == nil {
+		err = wrongCodeErr
+	}
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, auth.ErrTokenNotFound) || errors.Is(err, auth.ErrTokenExpired) || errors.Is(err, auth.ErrWrongCode) {
+			return authorizationErr
+		}
+		return NewHTTPError(http.StatusInternalServerError, ErrorCodeDatabaseFailure, "unexpected database failure")
+	}
+
+	token, err := a.signIn(r, user, AuthenticationMethodOTP)
+	if err != nil {
+		return err
+	}
+
+	return writeResponseJSON(w, http.StatusOK, token)
+}
+
+// tokenWebAuthnGrantFlow processes grant flow with a passkey asserted for a challenge of EndpointWebAuthnChallenge.
+// Passkeys require user verification, so the session is verified with a second factor.
+func (a *SurgeAPI) tokenWebAuthnGrantFlow(w http.ResponseWriter, r *http.Request) error {
+	if a.webAuthn == nil {
+		return UnprocessableEntityError(ErrorCodeDisabledGrantType, "webauthn is not configured")
+	}
+
+	body, err := utilities.GetBodyJson[tokenWebAuthnGrantTypeRequest](r)
 	if err != nil {
 		return err
 	}
 
+	if body.ChallengeID == uuid.Nil || len(body.Credential) == 0 {
+		return BadRequestError(ErrorCodeMissingField, "challenge_id and credential are required")
+	}
+
+	var response *AccessTokenResponse
+	err = a.Transaction(r.Context(), func(tx *sql.Tx, queries *schema.Queries) error {
+		user, err := auth.FinishWebAuthnSignIn(queries, r.Context(), a.config, a.webAuthn, body.ChallengeID, body.Credential)
+		if err != nil {
+			return err
+		}
+
+		session, err := a.createSession(r, queries, user, AuthenticationMethodWebAuthn)
+		if err != nil {
+			return err
+		}
+		session, err = queries.UpdateSessionAuthentication(r.Context(), schema.UpdateSessionAuthenticationParams{
+			ID:  session.ID,
+			Aal: AAL2,
+			Amr: session.Amr,
+		})
+		if err != nil {
+			return err
+		}
+
+		response, err = a.issueToken(r.Context(), queries, user, session, nil)
+		return err
+	})
+	if err != nil {
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) {
+			return httpErr
+		}
+		if errors.Is(err, auth.ErrChallengeNotFound) || errors.Is(err, auth.ErrChallengeExpired) || errors.Is(err, auth.ErrWebAuthnFailed) {
+			return UnauthorizedError(ErrorCodeInvalidCredentials, "passkey is invalid or challenge has expired")
+		}
+		return NewHTTPError(http.StatusInternalServerError, ErrorCodeDatabaseFailure, "unexpected database failure")
+	}
+
 	return writeResponseJSON(w, http.StatusOK, response)
 }
 
-func (a *SurgeAPI) issueToken(ctx context.Context, user *schema.AuthUser) (*AccessTokenResponse, error) {
-	logger := logrus.WithContext(ctx).WithField("user", user.ID)
+// tokenIDTokenGrantFlow signs in with an id token a native SDK of the provider issued to the app,
+// finding or creating the user of the identity the same way the external provider callback does
+func (a *SurgeAPI) tokenIDTokenGrantFlow(w http.ResponseWriter, r *http.Request) error {
+	body, err := utilities.GetBodyJson[tokenIDTokenGrantTypeRequest](r)
+	if err != nil {
+		return err
+	}
+
+	if body.Provider == "" || body.IDToken == "" {
+		return BadRequestError(ErrorCodeMissingField, "provider and id_token are required")
+	}
+
+	p, err := provider.Provider(r.Context(), a.config, body.Provider, "")
+	if err != nil {
+		return BadRequestError(ErrorCodeInvalidProviderType, "unsupported provider: %+v", err)
+	}
+
+	idTokenProvider, ok := p.(provider.IDTokenProvider)
+	if !ok {
+		return BadRequestError(ErrorCodeProviderIDTokenUnsupported, "provider %s does not support id tokens", body.Provider)
+	}
 
-	accessTokenString, expiresAt, err := a.generateAccessToken(user)
+	idToken, userData, err := idTokenProvider.ParseIDToken(r.Context(), body.IDToken, provider.ParseIDTokenOptions{
+		SkipAccessTokenCheck: body.AccessToken == "",
+		AccessToken:          body.AccessToken,
+	})
+	if err != nil {
+		return UnauthorizedError(ErrorCodeInvalidIDToken, "id token is invalid: %+v", err)
+	}
+
+	if !verifyIDTokenNonce(idToken.Nonce, body.Nonce) {
+		return UnauthorizedError(ErrorCodeInvalidIDToken, "nonce of the id token does not match")
+	}
+
+	if len(userData.Emails) == 0 {
+		return UnprocessableEntityError(ErrorCodeInvalidIDToken, "id token of provider %s has no email", body.Provider)
+	}
+
+	selectPrimaryEmail(userData)
+
+	user, err := a.findOrCreateExternalUser(r, strings.ToLower(body.Provider), userData)
+	if err != nil {
+		return err
+	}
+
+	if auth.IsUserBanned(user) {
+		return userBannedError(user)
+	}
+
+	token, err := a.signIn(r, user, AuthenticationMethodIDToken)
+	if err != nil {
+		return err
+	}
+
+	return writeResponseJSON(w, http.StatusOK, token)
+}
+
+// verifyIDTokenNonce checks the nonce of the id token is the raw nonce or its SHA-256 hex digest,
+// which is what Apple puts in id tokens. Either both or neither have to be present.
+func verifyIDTokenNonce(tokenNonce string, nonce string) bool {
+	if tokenNonce == "" || nonce == "" {
+		return tokenNonce == nonce
+	}
+
+	hashed := sha256.Sum256([]byte(nonce))
+	return subtle.ConstantTimeCompare([]byte(tokenNonce), []byte(nonce)) == 1 ||
+		subtle.ConstantTimeCompare([]byte(tokenNonce), []byte(hex.EncodeToString(hashed[:]))) == 1
+}
+
+// signIn completes signing in of the user authenticated by method with a first factor. Users with a verified factor
+// get an mfa token to complete signing in with it instead of a token pair.
+func (a *SurgeAPI) signIn(r *http.Request, user *schema.AuthUser, method AuthenticationMethod) (SignInResponse, error) {
+	factors, err := a.queries.ListVerifiedFactorsOfUser(r.Context(), user.ID)
+	if err != nil {
+		return nil, NewHTTPError(http.StatusInternalServerError, ErrorCodeDatabaseFailure, "unexpected database failure")
+	}
+	if len(factors) > 0 {
+		return a.createMFARequiredResponse(r, user, method, factors)
+	}
+
+	return a.issueTokenWithNewSession(r, user, method)
+}
+
+// issueTokenWithNewSession creates a new session for the user authenticated by method and issues a token pair bound to it
+func (a *SurgeAPI) issueTokenWithNewSession(r *http.Request, user *schema.AuthUser, method AuthenticationMethod) (*AccessTokenResponse, error) {
+	var response *AccessTokenResponse
+
+	err := a.Transaction(r.Context(), func(tx *sql.Tx, queries *schema.Queries) error {
+		session, err := a.createSession(r, queries, user, method)
+		if err != nil {
+			return err
+		}
+
+		response, err = a.issueToken(r.Context(), queries, user, session, nil)
+		return err
+	})
+	if err != nil {
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) {
+			return nil, httpErr
+		}
+		return nil, InternalServerError("failed to create session")
+	}
+
+	return response, nil
+}
+
+// issueToken issues a token pair within the session, parent is the refresh token being rotated if any
+func (a *SurgeAPI) issueToken(ctx context.Context, queries *schema.Queries, user *schema.AuthUser, session *schema.AuthSession, parent *schema.AuthRefreshToken) (*AccessTokenResponse, error) {
+	logger := logrus.WithContext(ctx).WithField("user", user.ID).WithField("session", session.ID)
+
+	accessTokenString, expiresAt, err := a.generateAccessToken(user, session)
 	if err != nil {
 		var httpErr *HTTPError
 		if errors.As(err, &httpErr) {
@@ -170,38 +555,52 @@ func (a *SurgeAPI) issueToken(ctx context.Context, user *schema.AuthUser) (*Acce
 		logger.WithError(err).Errorln("failed to generate access accessToken")
 		return nil, InternalServerError("failed to generate access accessToken")
 	}
-	refreshToken, err := a.generateRefreshToken(ctx, a.queries, user)
+	refreshToken, err := a.generateRefreshToken(ctx, queries, user, session, parent)
 	if err != nil {
 		return nil, InternalServerError("failed to create refresh accessToken")
 	}
 
 	return &AccessTokenResponse{
 		AccessToken:  accessTokenString,
-		RefreshToken: refreshToken.Token.String,
+		RefreshToken: refreshToken,
 		ExpiresIn:    a.config.JWT.ExpiresAfter,
 		ExpiresAt:    expiresAt,
 		User:         NewUserResponse(user),
 	}, nil
 }
 
-func (a *SurgeAPI) generateRefreshToken(ctx context.Context, q *schema.Queries, user *schema.AuthUser) (*schema.AuthRefreshToken, error) {
+// generateRefreshToken stores a hash of a new refresh token and returns the token itself
+func (a *SurgeAPI) generateRefreshToken(ctx context.Context, q *schema.Queries, user *schema.AuthUser, session *schema.AuthSession, parent *schema.AuthRefreshToken) (string, error) {
 	logger := logrus.WithContext(ctx).WithField("user", user.ID)
 
-	token, err := q.CreateRefreshToken(ctx, schema.CreateRefreshTokenParams{
-		UserID:  uuid.NullUUID{UUID: user.ID, Valid: user != nil},
-		Token:   storage.NewString(utilities.SecureToken()),
-		Revoked: false,
+	parentId := sql.NullInt64{}
+	if parent != nil {
+		parentId = sql.NullInt64{Int64: parent.ID, Valid: true}
+	}
+
+	token := utilities.SecureToken(utilities.WithLength(refreshTokenLength))
+
+	_, err := q.CreateRefreshToken(ctx, schema.CreateRefreshTokenParams{
+		UserID:    uuid.NullUUID{UUID: user.ID, Valid: user != nil},
+		SessionID: uuid.NullUUID{UUID: session.ID, Valid: true},
+		ParentID:  parentId,
+		Token:     storage.NewString(a.hashRefreshToken(token)),
+		Revoked:   false,
 	})
 	if err != nil {
 		logger.Errorf("Failed to create refresh accessToken")
-		return nil, err
+		return "", err
 	}
 
 	return token, nil
 }
 
+func (a *SurgeAPI) hashRefreshToken(token string) string {
+	return utilities.HashToken(token, []byte(a.config.JWT.RefreshTokenHashKey))
+}
+
 // generateAccessToken generates accessToken with configured JWKs in configuration and returns (accessToken, expiresAt, error)
-func (a *SurgeAPI) generateAccessToken(user *schema.AuthUser) (string, int64, error) {
+func (a *SurgeAPI) generateAccessToken(user *schema.AuthUser, session *schema.AuthSession) (string, int64, error) {
 	//logger := logrus.WithField("user", user.ID).WithField("where", "access_token_generation")
 
 	issuedAt := time.Now().UTC()
@@ -213,8 +612,11 @@ func (a *SurgeAPI) generateAccessToken(user *schema.AuthUser) (string, int64, er
 			IssuedAt:  jwt.NewNumericDate(issuedAt),
 			ExpiresAt: jwt.NewNumericDate(expiresAt),
 		},
-		Email:    storage.NullStringToPointer(user.Email),
-		Username: storage.NullStringToPointer(user.Username),
+		Email:     storage.NullStringToPointer(user.Email),
+		Username:  storage.NullStringToPointer(user.Username),
+		SessionID: session.ID.String(),
+		AAL:       session.Aal,
+		AMR:       getSessionAMR(session),
 	}
 
 	// Acquire signing JWK
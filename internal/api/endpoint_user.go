@@ -5,6 +5,10 @@ import (
 	"errors"
 	"github.com/google/uuid"
 	"net/http"
+	"surge/internal/auth"
+	"surge/internal/schema"
+	"surge/internal/storage"
+	"surge/internal/utilities"
 )
 
 // EndpointUser returns user data from logged in session
@@ -23,13 +27,200 @@ func (a *SurgeAPI) EndpointUser(w http.ResponseWriter, r *http.Request) error {
 		return BadRequestError(ErrorCodeBadJWT, "token subject is not a uuid")
 	}
 
-	user, err := a.queries.GetUser(r.Context(), userId)
+	user, err := getSubjectUser(r.Context(), a.queries, userId)
 	if err != nil {
-		if errors.Is(sql.ErrNoRows, err) {
-			return ForbiddenError(ErrorCodeUserNotFound, "token subject user does not exist")
-		}
 		return err
 	}
 
 	return writeResponseJSON(w, http.StatusOK, NewUserResponse(user))
 }
+
+// EndpointUpdateUser updates user data of logged in session, fields absent from the body are left unchanged
+func (a *SurgeAPI) EndpointUpdateUser(w http.ResponseWriter, r *http.Request) error {
+	claims := getClaims(r.Context())
+	if claims == nil {
+		return InternalServerError("failed to read claims")
+	}
+
+	userId, err := claims.GetSubjectUUID()
+	if err != nil {
+		return BadRequestError(ErrorCodeBadJWT, "token subject is not a uuid")
+	}
+
+	body, err := utilities.GetBodyJson[UpdateUserRequest](r)
+	if err != nil {
+		return BadRequestError(ErrorCodeInvalidJSON, "failed to parse request body: %+v", err)
+	}
+
+	var updatedUser *schema.AuthUser
+	err = a.Transaction(r.Context(), func(tx *sql.Tx, queries *schema.Queries) error {
+		user, err := getSubjectUser(r.Context(), queries, userId)
+		if err != nil {
+			return err
+		}
+
+		updatedUser, err = auth.UpdateUser(queries, r.Context(), user, auth.UpdateUserOptions{
+			Username: body.Username,
+			Phone:    body.Phone,
+			Metadata: auth.UserMetadata{
+				Avatar:    body.Metadata.Avatar,
+				FirstName: body.Metadata.FirstName,
+				LastName:  body.Metadata.LastName,
+				Birthdate: body.Metadata.Birthdate,
+				Extra:     body.Metadata.Extra,
+			},
+		})
+		if err != nil {
+			return err
+		}
+
+		// Changed email is kept pending until the new email is confirmed
+		if body.Email != nil && (!user.Email.Valid || user.Email.String != *body.Email) {
+			updatedUser, err = a.changeOrSendEmailChange(r, queries, updatedUser, *body.Email)
+			if err != nil {
+				return err
+			}
+		}
+
+		// Changed phone has to be confirmed again
+		previousPhone := storage.InterfaceToStringPointer(user.Phone)
+		if body.Phone != nil && (previousPhone == nil || *previousPhone != *body.Phone) {
+			updatedUser, err = auth.SetPhoneConfirmed(queries, r.Context(), updatedUser, false)
+			if err != nil {
+				return err
+			}
+			updatedUser, err = a.confirmOrSendPhoneConfirmation(r, queries, updatedUser)
+		}
+		return err
+	})
+	if err != nil {
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) {
+			return httpErr
+		}
+		return userOptionsError(r, err)
+	}
+
+	return writeResponseJSON(w, http.StatusOK, NewUserResponse(updatedUser))
+}
+
+// EndpointChangePassword changes password of the logged in user after verifying the current password.
+// Users with a verified factor have to be signed in with a second factor.
+func (a *SurgeAPI) EndpointChangePassword(w http.ResponseWriter, r *http.Request) error {
+	claims := getClaims(r.Context())
+	if claims == nil {
+		return InternalServerError("failed to read claims")
+	}
+
+	userId, err := claims.GetSubjectUUID()
+	if err != nil {
+		return BadRequestError(ErrorCodeBadJWT, "token subject is not a uuid")
+	}
+
+	body, err := utilities.GetBodyJson[ChangePasswordRequest](r)
+	if err != nil {
+		return BadRequestError(ErrorCodeInvalidJSON, "failed to parse request body: %+v", err)
+	}
+
+	var sessionId uuid.UUID
+	if body.RevokeOtherSessions {
+		sessionId, err = claims.GetSessionUUID()
+		if err != nil {
+			return BadRequestError(ErrorCodeBadJWT, "token is not bound to a session, other sessions can't be revoked")
+		}
+	}
+
+	var updatedUser *schema.AuthUser
+	err = a.Transaction(r.Context(), func(tx *sql.Tx, queries *schema.Queries) error {
+		user, err := getSubjectUser(r.Context(), queries, userId)
+		if err != nil {
+			return err
+		}
+
+		if err := requireAAL2IfEnrolled(r.Context(), queries, user, claims); err != nil {
+			return err
+		}
+
+		updatedUser, err = auth.ChangePassword(queries, r.Context(), user, auth.ChangePasswordOptions{
+			CurrentPassword: body.CurrentPassword,
+			NewPassword:     body.NewPassword,
+		})
+		if err != nil {
+			return err
+		}
+
+		if body.RevokeOtherSessions {
+			return a.revokeOtherSessions(r.Context(), queries, userId, sessionId)
+		}
+		return nil
+	})
+	if err != nil {
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) {
+			return httpErr
+		}
+		return userOptionsError(r, err)
+	}
+
+	return writeResponseJSON(w, http.StatusOK, NewUserResponse(updatedUser))
+}
+
+// EndpointDeleteUser deletes the logged in user, soft deleting if configured.
+// Users with a verified factor have to be signed in with a second factor.
+func (a *SurgeAPI) EndpointDeleteUser(w http.ResponseWriter, r *http.Request) error {
+	claims := getClaims(r.Context())
+	if claims == nil {
+		return InternalServerError("failed to read claims")
+	}
+
+	userId, err := claims.GetSubjectUUID()
+	if err != nil {
+		return BadRequestError(ErrorCodeBadJWT, "token subject is not a uuid")
+	}
+
+	err = a.Transaction(r.Context(), func(tx *sql.Tx, queries *schema.Queries) error {
+		user, err := getSubjectUser(r.Context(), queries, userId)
+		if err != nil {
+			return err
+		}
+
+		if err := requireAAL2IfEnrolled(r.Context(), queries, user, claims); err != nil {
+			return err
+		}
+
+		return a.deleteUser(r, queries, user, a.config.Auth.SoftDeleteUsers, userDeletedBySelf)
+	})
+	if err != nil {
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) {
+			return httpErr
+		}
+		return InternalServerError("failed to delete user")
+	}
+
+	a.clearCookieTokens(a.config, w)
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+const (
+	userDeletedBySelf  = "self"
+	userDeletedByAdmin = "admin"
+)
+
+// deleteUser deletes or soft deletes the user, recording who deleted the user in the audit log
+func (a *SurgeAPI) deleteUser(r *http.Request, queries *schema.Queries, user *schema.AuthUser, soft bool, deletedBy string) error {
+	err := auth.RecordAuditEvent(queries, r.Context(), user.ID, auth.AuditActionUserDeleted, utilities.GetIPAddress(r), map[string]interface{}{
+		"soft":       soft,
+		"deleted_by": deletedBy,
+	})
+	if err != nil {
+		return err
+	}
+
+	if soft {
+		_, err = auth.SoftDeleteUser(queries, r.Context(), user)
+		return err
+	}
+	return auth.DeleteUser(queries, r.Context(), user)
+}
@@ -0,0 +1,169 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/url"
+	"surge/internal/auth"
+	"surge/internal/schema"
+	"surge/internal/utilities"
+)
+
+type VerifyType = string
+
+const (
+	VerifyTypeSignup      VerifyType = "signup"
+	VerifyTypeEmailChange VerifyType = "email_change"
+	VerifyTypeMagicLink   VerifyType = "magiclink"
+)
+
+type VerifyRequest struct {
+	Type  VerifyType `json:"type"`
+	Token string     `json:"token"`
+}
+
+// EndpointVerify verifies the token from a link sent to the user and redirects with issued tokens
+func (a *SurgeAPI) EndpointVerify(w http.ResponseWriter, r *http.Request) error {
+	ctx := context.WithValue(r.Context(), contextExternalReferrerKey, GetRequestReferrer(r, a.config))
+	a.redirectErrors(a.internalVerifyRedirect, w, r.WithContext(ctx))
+	return nil
+}
+
+func (a *SurgeAPI) internalVerifyRedirect(w http.ResponseWriter, r *http.Request) error {
+	query := r.URL.Query()
+
+	token, err := a.verify(r, query.Get("type"), query.Get("token"))
+	if err != nil {
+		return err
+	}
+
+	http.Redirect(w, r, token.MakeRedirectUrl(a.getExternalRedirectURL(r), url.Values{}), http.StatusFound)
+	return nil
+}
+
+// EndpointVerifyPost verifies the token and responds with issued tokens
+func (a *SurgeAPI) EndpointVerifyPost(w http.ResponseWriter, r *http.Request) error {
+	body, err := utilities.GetBodyJson[VerifyRequest](r)
+	if err != nil {
+		return BadRequestError(ErrorCodeInvalidJSON, "failed to parse request body: %+v", err)
+	}
+
+	token, err := a.verify(r, body.Type, body.Token)
+	if err != nil {
+		return err
+	}
+
+	return writeResponseJSON(w, http.StatusOK, token)
+}
+
+func (a *SurgeAPI) verify(r *http.Request, verifyType VerifyType, token string) (SignInResponse, error) {
+	if token == "" {
+		return nil, BadRequestError(ErrorCodeMissingField, "token is empty or missing")
+	}
+
+	var user *schema.AuthUser
+	err := a.Transaction(r.Context(), func(tx *sql.Tx, queries *schema.Queries) error {
+		var err error
+
+		switch verifyType {
+		case VerifyTypeSignup:
+			user, err = a.verifyConfirmation(r.Context(), queries, token)
+		case VerifyTypeEmailChange:
+			user, err = a.verifyEmailChange(r.Context(), queries, token)
+		case VerifyTypeMagicLink:
+			user, err = a.verifyMagicLink(r.Context(), queries, token)
+		default:
+			return BadRequestError(ErrorCodeInvalidVerifyType, "invalid verify type '%s'", verifyType)
+		}
+
+		return err
+	})
+	if err != nil {
+		return nil, oneTimeTokenError(err)
+	}
+
+	// Confirmation, email change and magic links all prove access to the email
+	return a.signIn(r, user, AuthenticationMethodMagicLink)
+}
+
+// verifyConfirmation confirms email the confirmation token was sent to
+func (a *SurgeAPI) verifyConfirmation(ctx context.Context, queries *schema.Queries, token string) (*schema.AuthUser, error) {
+	oneTimeToken, err := auth.ConsumeOneTimeToken(queries, ctx, a.config, auth.OneTimeTokenConfirmation, token)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := queries.GetUser(ctx, oneTimeToken.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Email was changed after the confirmation was sent
+	if user.Email.String != oneTimeToken.RelatesTo {
+		return nil, auth.ErrTokenNotFound
+	}
+
+	return auth.SetEmailConfirmed(queries, ctx, user, true)
+}
+
+// verifyEmailChange changes email of the user to the new email the link was sent to
+func (a *SurgeAPI) verifyEmailChange(ctx context.Context, queries *schema.Queries, token string) (*schema.AuthUser, error) {
+	oneTimeToken, err := auth.ConsumeOneTimeToken(queries, ctx, a.config, auth.OneTimeTokenEmailChange, token)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := queries.GetUser(ctx, oneTimeToken.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Another email change was requested after the link was sent
+	if user.EmailChange.String != oneTimeToken.RelatesTo {
+		return nil, auth.ErrTokenNotFound
+	}
+
+	return auth.ConfirmEmailChange(queries, ctx, user)
+}
+
+// verifyMagicLink signs in the user the magic link was sent to, confirming the email as well
+func (a *SurgeAPI) verifyMagicLink(ctx context.Context, queries *schema.Queries, token string) (*schema.AuthUser, error) {
+	oneTimeToken, err := auth.ConsumeOneTimeToken(queries, ctx, a.config, auth.OneTimeTokenMagicLink, token)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := queries.GetUser(ctx, oneTimeToken.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Email was changed after the magic link was sent
+	if user.Email.String != oneTimeToken.RelatesTo {
+		return nil, auth.ErrTokenNotFound
+	}
+
+	if user.EmailConfirmedAt.Valid {
+		return user, nil
+	}
+	return auth.SetEmailConfirmed(queries, ctx, user, true)
+}
+
+// oneTimeTokenError converts errors from consuming one-time tokens into HTTPError
+func oneTimeTokenError(err error) error {
+	var httpErr *HTTPError
+	switch {
+	case errors.As(err, &httpErr):
+		return httpErr
+	case errors.Is(err, auth.ErrTokenNotFound):
+		return ForbiddenError(ErrorCodeOneTimeTokenNotFound, "token is invalid or was already used")
+	case errors.Is(err, auth.ErrTokenExpired):
+		return ForbiddenError(ErrorCodeOneTimeTokenExpired, "token has expired")
+	case errors.Is(err, auth.ErrDuplicateEmail):
+		return ConflictError("email is already used by another user")
+	default:
+		return InternalServerError("failed to verify token: %+v", err)
+	}
+}
@@ -0,0 +1,27 @@
+package api
+
+import (
+	"net/http"
+	"surge/internal/auth"
+	"time"
+)
+
+// EndpointWebAuthnChallenge starts signing in with a passkey, the assertion is exchanged for tokens with the webauthn grant type
+func (a *SurgeAPI) EndpointWebAuthnChallenge(w http.ResponseWriter, r *http.Request) error {
+	if a.webAuthn == nil {
+		return UnprocessableEntityError(ErrorCodeWebAuthnDisabled, "webauthn is not configured")
+	}
+
+	challenge, options, err := auth.BeginWebAuthnSignIn(a.queries, r.Context(), a.webAuthn)
+	if err != nil {
+		return InternalServerError("failed to create webauthn challenge")
+	}
+
+	expiresAt := challenge.CreatedAt.Add(time.Second * time.Duration(a.config.WebAuthn.ChallengeExpiresAfter))
+
+	return writeResponseJSON(w, http.StatusOK, &WebAuthnChallengeResponse{
+		ID:        challenge.ID,
+		ExpiresAt: expiresAt.Unix(),
+		WebAuthn:  options,
+	})
+}
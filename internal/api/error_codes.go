@@ -15,6 +15,7 @@ const (
 	ErrorCodeInvalidField ErrorCode = "invalid_field"
 
 	ErrorCodeInvalidCredentials ErrorCode = "invalid_credentials"
+	ErrorCodeNoPassword         ErrorCode = "no_password"
 
 	ErrorCodeConflict ErrorCode = "conflict"
 
@@ -26,15 +27,46 @@ const (
 	ErrorCodeBadOAuth2State    ErrorCode = "bad_oauth2_state"
 	ErrorCodeBadOAuth2Callback ErrorCode = "bad_oauth2_callback"
 
-	ErrorCodeProviderOAuth2Unsupported ErrorCode = "provider_oauth2_unsupported"
+	ErrorCodeProviderOAuth2Unsupported  ErrorCode = "provider_oauth2_unsupported"
+	ErrorCodeProviderIDTokenUnsupported ErrorCode = "provider_id_token_unsupported"
+	ErrorCodeInvalidIDToken             ErrorCode = "invalid_id_token"
+
+	ErrorCodeIdentityNotFound      ErrorCode = "identity_not_found"
+	ErrorCodeIdentityAlreadyExists ErrorCode = "identity_already_exists"
+	ErrorCodeLastSignInMethod      ErrorCode = "last_sign_in_method"
+
+	ErrorCodeProviderEmailNotVerified ErrorCode = "provider_email_not_verified"
 
 	ErrorCodeUserNotFound ErrorCode = "user_not_found"
+	ErrorCodeUserBanned   ErrorCode = "user_banned"
 
 	ErrorCodeRefreshNotFoundToken ErrorCode = "refresh_token_not_found"
 	ErrorCodeRefreshTokenRevoked  ErrorCode = "refresh_token_revoked"
 
+	ErrorCodeSessionNotFound ErrorCode = "session_not_found"
+
+	ErrorCodeEmailNotConfirmed ErrorCode = "email_not_confirmed"
+	ErrorCodePhoneNotConfirmed ErrorCode = "phone_not_confirmed"
+
+	ErrorCodeInvalidVerifyType    ErrorCode = "invalid_verify_type"
+	ErrorCodeOneTimeTokenNotFound ErrorCode = "one_time_token_not_found"
+	ErrorCodeOneTimeTokenExpired  ErrorCode = "one_time_token_expired"
+	ErrorCodeOneTimeTokenTooSoon  ErrorCode = "one_time_token_too_soon"
+
+	ErrorCodeInvalidFactorType     ErrorCode = "invalid_factor_type"
+	ErrorCodeTooManyFactors        ErrorCode = "too_many_mfa_factors"
+	ErrorCodeFactorNotFound        ErrorCode = "mfa_factor_not_found"
+	ErrorCodeFactorNotVerified     ErrorCode = "mfa_factor_not_verified"
+	ErrorCodeChallengeNotFound     ErrorCode = "mfa_challenge_not_found"
+	ErrorCodeChallengeExpired      ErrorCode = "mfa_challenge_expired"
+	ErrorCodeMFAVerificationFailed ErrorCode = "mfa_verification_failed"
+	ErrorCodeFactorLocked          ErrorCode = "mfa_factor_locked"
+	ErrorCodeInsufficientAssurance ErrorCode = "insufficient_aal"
+	ErrorCodeWebAuthnDisabled      ErrorCode = "webauthn_disabled"
+
 	ErrorCodeNoAuthorization ErrorCode = "no_authorization"
 	ErrorCodeBadJWT          ErrorCode = "bad_jwt"
+	ErrorCodeNotAdmin        ErrorCode = "not_admin"
 
 	ErrorCodeInvalidLogoutScope ErrorCode = "invalid_logout_scope"
 )
@@ -3,9 +3,13 @@ package api
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-playground/validator/v10"
 	"github.com/sirupsen/logrus"
 	"net/http"
+	"surge/internal/auth"
+	"surge/internal/utilities"
 )
 
 func HandleResponseError(err error, w http.ResponseWriter, r *http.Request) {
@@ -54,3 +58,52 @@ func HandleResponseError(err error, w http.ResponseWriter, r *http.Request) {
 		log.WithError(jsonErr).Warn("Failed to send JSON on ResponseWriter")
 	}
 }
+
+// userOptionsError converts errors from creating or updating a user into HTTPError
+func userOptionsError(r *http.Request, err error) error {
+	var validationErrors validator.ValidationErrors
+
+	switch {
+	case errors.Is(err, auth.ErrMissingField),
+		errors.Is(err, auth.ErrRequiredUsername),
+		errors.Is(err, auth.ErrRequiredEmail),
+		errors.Is(err, auth.ErrRequiredPhone):
+		return BadRequestError(ErrorCodeMissingField, err.Error())
+	case errors.Is(err, auth.ErrInvalidEmail),
+		errors.Is(err, auth.ErrInvalidUsername),
+		errors.Is(err, auth.ErrInvalidPassword):
+		return BadRequestError(ErrorCodeInvalidField, err.Error())
+	case errors.Is(err, auth.ErrWrongPassword):
+		return UnauthorizedError(ErrorCodeInvalidCredentials, "current password does not match")
+	case errors.Is(err, auth.ErrNoPassword):
+		return UnprocessableEntityError(ErrorCodeNoPassword, err.Error())
+	case errors.Is(err, auth.ErrDuplicateEmail),
+		errors.Is(err, auth.ErrDuplicateUsername),
+		errors.Is(err, auth.ErrDuplicatePhone):
+		return ConflictError(err.Error())
+	case errors.Is(err, auth.ErrTokenTooSoon):
+		return TooManyRequestsError(ErrorCodeOneTimeTokenTooSoon, "a code was sent recently, try again later")
+	case errors.Is(err, auth.ErrDatabaseJob):
+		return InternalServerError("failed to do database action")
+	case errors.As(err, &validationErrors):
+		httpErr := NewBuilder().
+			UseRequest(r).
+			SetStatus(http.StatusBadRequest).
+			SetErrorCode(ErrorCodeInvalidJSON).
+			SetDetails(utilities.Map(
+				validationErrors,
+				func(t validator.FieldError) any {
+					return map[string]any{
+						"tag":       t.Tag(),
+						"namespace": t.Namespace(),
+						"field":     t.Field(),
+						"error":     fmt.Sprintf("failed validation for field '%s' on the '%s' tag", t.Field(), t.Tag()),
+					}
+				},
+			)).
+			Build()
+		return httpErr
+	}
+
+	return InternalServerError("unknown error during processing user")
+}
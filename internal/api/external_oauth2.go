@@ -28,19 +28,24 @@ func (a *SurgeAPI) handleOAuthCallback(r *http.Request) (*OAuth2ProviderData, er
 }
 
 func (a *SurgeAPI) loadOAuth2StateToContextMiddleware(w http.ResponseWriter, r *http.Request) (context.Context, error) {
-	state := r.URL.Query().Get("state")
+	// Providers using response_mode=form_post such as Apple post the state instead
+	state := r.FormValue("state")
 	if state == "" {
 		return nil, BadRequestError(ErrorCodeBadOAuth2Callback, "OAuth state parameter missing")
 	}
 
-	ctx := r.Context()
-	return a.loadExternalStateToContext(ctx, state)
+	ctx, err := a.loadExternalStateToContext(r, state)
+	if err != nil {
+		return nil, err
+	}
+
+	// The linking nonce is only good for a single callback
+	a.clearCookieToken(a.config, linkingNonceCookieName, w)
+	return ctx, nil
 }
 
 func (a *SurgeAPI) oauth2Callback(r *http.Request, providerType string) (*OAuth2ProviderData, error) {
-	query := r.URL.Query()
-
-	codeQuery := query.Get("code")
+	codeQuery := r.FormValue("code")
 	if codeQuery == "" {
 		return nil, BadRequestError(ErrorCodeBadOAuth2Callback, "OAuth state parameter missing")
 	}
@@ -60,6 +65,12 @@ func (a *SurgeAPI) oauth2Callback(r *http.Request, providerType string) (*OAuth2
 		return nil, InternalServerError("error acquiring user data from external provider %s: %+v", providerType, err)
 	}
 
+	if formProvider, ok := p.(provider.CallbackFormProvider); ok {
+		if err := formProvider.MergeCallbackForm(data, r.PostForm); err != nil {
+			return nil, BadRequestError(ErrorCodeBadOAuth2Callback, "invalid user data posted by provider %s: %+v", providerType, err)
+		}
+	}
+
 	return &OAuth2ProviderData{
 		userData:     data,
 		accessToken:  token.AccessToken,
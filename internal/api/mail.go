@@ -0,0 +1,149 @@
+package api
+
+import (
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"net/url"
+	"surge/internal/auth"
+	"surge/internal/mailer"
+	"surge/internal/schema"
+	"time"
+)
+
+// confirmOrSendConfirmation confirms email of the user right away if configured, otherwise mails a confirmation link.
+// Failing to mail is only logged, so a signup is not rolled back by it and the link can be resent later.
+func (a *SurgeAPI) confirmOrSendConfirmation(r *http.Request, queries *schema.Queries, user *schema.AuthUser) (*schema.AuthUser, error) {
+	if !user.Email.Valid {
+		return user, nil
+	}
+
+	if a.config.Auth.AutoConfirmEmail {
+		return auth.SetEmailConfirmed(queries, r.Context(), user, true)
+	}
+
+	return user, a.sendConfirmation(r, queries, user)
+}
+
+// sendConfirmation mails a link confirming email of the user, failing to mail is only logged
+func (a *SurgeAPI) sendConfirmation(r *http.Request, queries *schema.Queries, user *schema.AuthUser) error {
+	token, err := auth.CreateOneTimeToken(queries, r.Context(), a.config, user, auth.CreateOneTimeTokenOptions{
+		Type:           auth.OneTimeTokenConfirmation,
+		RelatesTo:      user.Email.String,
+		ExpiresAfter:   time.Second * time.Duration(a.config.Mailer.ConfirmationExpiresAfter),
+		ResendInterval: time.Second * time.Duration(a.config.Mailer.OTPResendInterval),
+	})
+	if err != nil {
+		return err
+	}
+
+	link := a.makeVerifyLink(VerifyTypeSignup, token, GetRequestReferrer(r, a.config))
+	if err := a.mailer.Mail(r.Context(), mailer.NewConfirmationMessage(user.Email.String, link)); err != nil {
+		logrus.WithError(err).Error("failed to send confirmation mail")
+	}
+
+	return nil
+}
+
+// changeOrSendEmailChange changes email of the user right away if configured, otherwise keeps the new email pending
+// and mails a link confirming it to the new email, the email is only changed once the link is followed
+func (a *SurgeAPI) changeOrSendEmailChange(r *http.Request, queries *schema.Queries, user *schema.AuthUser, email string) (*schema.AuthUser, error) {
+	user, err := auth.SetEmailChange(queries, r.Context(), user, email)
+	if err != nil {
+		return nil, err
+	}
+
+	if a.config.Auth.AutoConfirmEmail {
+		return auth.ConfirmEmailChange(queries, r.Context(), user)
+	}
+
+	token, err := auth.CreateOneTimeToken(queries, r.Context(), a.config, user, auth.CreateOneTimeTokenOptions{
+		Type:         auth.OneTimeTokenEmailChange,
+		RelatesTo:    email,
+		ExpiresAfter: time.Second * time.Duration(a.config.Mailer.ConfirmationExpiresAfter),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	link := a.makeVerifyLink(VerifyTypeEmailChange, token, GetRequestReferrer(r, a.config))
+	if err := a.mailer.Mail(r.Context(), mailer.NewEmailChangeMessage(email, link)); err != nil {
+		return nil, InternalServerError("failed to send email change mail: %+v", err)
+	}
+
+	return user, nil
+}
+
+// makeVerifyLink creates a link to /v1/verify which redirects to redirectTo once verified
+func (a *SurgeAPI) makeVerifyLink(verifyType VerifyType, token string, redirectTo string) string {
+	q := url.Values{}
+	q.Set("type", verifyType)
+	q.Set("token", token)
+	q.Set("redirect_to", redirectTo)
+
+	return a.config.PublicURL + "/v1/verify?" + q.Encode()
+}
+
+// sendRecovery mails a link to reset password, the link leads to redirectTo with the token in the fragment.
+// Failing to mail is only logged, so the response doesn't reveal the user exists.
+func (a *SurgeAPI) sendRecovery(r *http.Request, queries *schema.Queries, user *schema.AuthUser) error {
+	token, err := auth.CreateOneTimeToken(queries, r.Context(), a.config, user, auth.CreateOneTimeTokenOptions{
+		Type:           auth.OneTimeTokenRecovery,
+		RelatesTo:      user.Email.String,
+		ExpiresAfter:   time.Second * time.Duration(a.config.Mailer.RecoveryExpiresAfter),
+		ResendInterval: time.Second * time.Duration(a.config.Mailer.OTPResendInterval),
+	})
+	if err != nil {
+		return err
+	}
+
+	q := url.Values{}
+	q.Set("type", auth.OneTimeTokenRecovery)
+	q.Set("token", token)
+	link := GetRequestReferrer(r, a.config) + "#" + q.Encode()
+
+	if err := a.mailer.Mail(r.Context(), mailer.NewRecoveryMessage(user.Email.String, link)); err != nil {
+		logrus.WithError(err).Error("failed to send recovery mail")
+	}
+
+	return nil
+}
+
+// sendMagicLink mails a link which signs the user in through /v1/verify
+func (a *SurgeAPI) sendMagicLink(r *http.Request, queries *schema.Queries, user *schema.AuthUser) error {
+	token, err := auth.CreateOneTimeToken(queries, r.Context(), a.config, user, auth.CreateOneTimeTokenOptions{
+		Type:           auth.OneTimeTokenMagicLink,
+		RelatesTo:      user.Email.String,
+		ExpiresAfter:   time.Second * time.Duration(a.config.Mailer.MagicLinkExpiresAfter),
+		ResendInterval: time.Second * time.Duration(a.config.Mailer.OTPResendInterval),
+	})
+	if err != nil {
+		return err
+	}
+
+	link := a.makeVerifyLink(VerifyTypeMagicLink, token, GetRequestReferrer(r, a.config))
+	if err := a.mailer.Mail(r.Context(), mailer.NewMagicLinkMessage(user.Email.String, link)); err != nil {
+		return InternalServerError("failed to send magic link mail: %+v", err)
+	}
+
+	return nil
+}
+
+// sendEmailOTP mails a code which signs the user in through the otp grant type
+func (a *SurgeAPI) sendEmailOTP(r *http.Request, queries *schema.Queries, user *schema.AuthUser) error {
+	code, err := auth.CreateOneTimeToken(queries, r.Context(), a.config, user, auth.CreateOneTimeTokenOptions{
+		Type:           auth.OneTimeTokenEmailOTP,
+		RelatesTo:      user.Email.String,
+		ExpiresAfter:   time.Second * time.Duration(a.config.Mailer.OTPExpiresAfter),
+		Numeric:        true,
+		ResendInterval: time.Second * time.Duration(a.config.Mailer.OTPResendInterval),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := a.mailer.Mail(r.Context(), mailer.NewOTPMessage(user.Email.String, code)); err != nil {
+		return InternalServerError("failed to send one-time password mail: %+v", err)
+	}
+
+	return nil
+}
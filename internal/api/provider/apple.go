@@ -0,0 +1,173 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+	"net/url"
+	"strings"
+	"surge/internal/conf"
+	"time"
+)
+
+const IssuerApple = "https://appleid.apple.com"
+
+var internalIssuerApple = IssuerApple
+
+// applePrivateRelayDomain is the domain of emails relaying to users hiding their email
+const applePrivateRelayDomain = "privaterelay.appleid.com"
+
+// appleClientSecretExpiresAfter is how long the generated client secret is valid, Apple allows up to 6 months
+const appleClientSecretExpiresAfter = 5 * time.Minute
+
+type appleUser struct {
+	Issuer         string       `json:"iss"`
+	Subject        string       `json:"sub"`
+	Email          string       `json:"email"`
+	EmailVerified  flexibleBool `json:"email_verified"`
+	IsPrivateEmail flexibleBool `json:"is_private_email"`
+}
+
+// appleFormUser is the user form field Apple posts to the callback, only on the first authorization
+type appleFormUser struct {
+	Name struct {
+		FirstName string `json:"firstName"`
+		LastName  string `json:"lastName"`
+	} `json:"name"`
+}
+
+type appleOAuth2Provider struct {
+	*oauth2.Config
+
+	oidc      *oidc.Provider
+	clientIDs []string
+}
+
+// NewAppleProvider creates a Sign in with Apple provider, the client secret is generated from the private key.
+// Apple posts the callback as a form since the name and email scopes require response_mode=form_post.
+func NewAppleProvider(ctx context.Context, config conf.SurgeAppleProviderConfiguration, scopes string) (OAuth2Provider, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	clientSecret, err := generateAppleClientSecret(config)
+	if err != nil {
+		return nil, err
+	}
+
+	oauthScopes := []string{
+		"name",
+		"email",
+	}
+
+	if scopes != "" {
+		oauthScopes = append(oauthScopes, strings.Split(scopes, ",")...)
+	}
+
+	oidcProvider, err := oidc.NewProvider(ctx, internalIssuerApple)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := oidcProvider.Endpoint()
+	endpoint.AuthStyle = oauth2.AuthStyleInParams
+
+	return &appleOAuth2Provider{
+		Config: &oauth2.Config{
+			ClientID:     config.ClientID[0],
+			ClientSecret: clientSecret,
+			Endpoint:     endpoint,
+			Scopes:       oauthScopes,
+			RedirectURL:  config.RedirectURI,
+		},
+		oidc:      oidcProvider,
+		clientIDs: config.ClientID,
+	}, nil
+}
+
+// generateAppleClientSecret signs the client secret JWT with the .p8 private key of the config
+func generateAppleClientSecret(config conf.SurgeAppleProviderConfiguration) (string, error) {
+	privateKey, err := jwt.ParseECPrivateKeyFromPEM([]byte(config.PrivateKey))
+	if err != nil {
+		return "", fmt.Errorf("provider: invalid apple private key: %w", err)
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.RegisteredClaims{
+		Issuer:    config.TeamID,
+		Subject:   config.ClientID[0],
+		Audience:  jwt.ClaimStrings{IssuerApple},
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(appleClientSecretExpiresAfter)),
+	})
+	token.Header["kid"] = config.KeyID
+
+	return token.SignedString(privateKey)
+}
+
+func (g appleOAuth2Provider) AuthCodeURL(state string, opts ...oauth2.AuthCodeOption) string {
+	return g.Config.AuthCodeURL(state, append(opts, oauth2.SetAuthURLParam("response_mode", "form_post"))...)
+}
+
+func (g appleOAuth2Provider) GetOAuthToken(code string) (*oauth2.Token, error) {
+	return g.Exchange(context.Background(), code)
+}
+
+func (g appleOAuth2Provider) GetUserData(ctx context.Context, tok *oauth2.Token) (*UserData, error) {
+	idToken := tok.Extra("id_token")
+	if idToken == nil {
+		return nil, fmt.Errorf("provider: apple did not return an id token")
+	}
+
+	_, data, err := ParseIDToken(ctx, g.oidc, &oidc.Config{
+		ClientID: g.Config.ClientID,
+	}, idToken.(string), ParseIDTokenOptions{
+		AccessToken: tok.AccessToken,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// ParseIDToken verifies id tokens of native apps, the bundle ids of the apps have to be configured as client ids
+func (g appleOAuth2Provider) ParseIDToken(ctx context.Context, idToken string, options ParseIDTokenOptions) (*oidc.IDToken, *UserData, error) {
+	token, data, err := ParseIDToken(ctx, g.oidc, nil, idToken, options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := verifyAudience(token, g.clientIDs); err != nil {
+		return nil, nil, err
+	}
+
+	return token, data, nil
+}
+
+// MergeCallbackForm reads the name from the user form field, which Apple only posts on the first authorization
+func (g appleOAuth2Provider) MergeCallbackForm(data *UserData, form url.Values) error {
+	raw := form.Get("user")
+	if raw == "" {
+		return nil
+	}
+
+	var user appleFormUser
+	if err := json.Unmarshal([]byte(raw), &user); err != nil {
+		return err
+	}
+
+	data.Claims.GivenName = user.Name.FirstName
+	data.Claims.FamilyName = user.Name.LastName
+	data.Claims.Name = strings.TrimSpace(user.Name.FirstName + " " + user.Name.LastName)
+
+	return nil
+}
+
+// IsApplePrivateRelayEmail reports whether the email relays to an Apple user hiding their email
+func IsApplePrivateRelayEmail(email string) bool {
+	return strings.HasSuffix(strings.ToLower(email), "@"+applePrivateRelayDomain)
+}
@@ -0,0 +1,110 @@
+package provider
+
+import (
+	"context"
+	"golang.org/x/oauth2"
+	"strings"
+	"surge/internal/conf"
+)
+
+type discordUser struct {
+	ID         string `json:"id"`
+	Username   string `json:"username"`
+	GlobalName string `json:"global_name"`
+	Avatar     string `json:"avatar"`
+	Email      string `json:"email"`
+	Verified   bool   `json:"verified"`
+}
+
+type discordOAuth2Provider struct {
+	*oauth2.Config
+
+	APIPath string
+}
+
+const (
+	defaultDiscordAPIBase = "discord.com"
+	discordCDNBase        = "https://cdn.discordapp.com"
+)
+
+func NewDiscordProvider(config conf.SurgeProviderConfiguration, scopes string) (OAuth2Provider, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	apiPath := chooseHost(config.URL, defaultDiscordAPIBase) + "/api"
+
+	oauthScopes := []string{
+		"email",
+		"identify",
+	}
+
+	if scopes != "" {
+		oauthScopes = append(oauthScopes, strings.Split(scopes, ",")...)
+	}
+
+	return &discordOAuth2Provider{
+		Config: &oauth2.Config{
+			ClientID:     config.ClientID[0],
+			ClientSecret: config.ClientSecret,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  apiPath + "/oauth2/authorize",
+				TokenURL: apiPath + "/oauth2/token",
+			},
+			Scopes:      oauthScopes,
+			RedirectURL: config.RedirectURI,
+		},
+		APIPath: apiPath,
+	}, nil
+}
+
+func (g discordOAuth2Provider) GetOAuthToken(code string) (*oauth2.Token, error) {
+	return g.Exchange(context.Background(), code)
+}
+
+func (g discordOAuth2Provider) GetUserData(ctx context.Context, tok *oauth2.Token) (*UserData, error) {
+	var u discordUser
+	if err := makeRequest(ctx, tok, g.Config, g.APIPath+"/users/@me", &u); err != nil {
+		return nil, err
+	}
+
+	var data UserData
+
+	if u.Email != "" {
+		data.Emails = append(data.Emails, UserEmail{
+			Email:    u.Email,
+			Verified: u.Verified,
+			Primary:  true,
+		})
+	}
+
+	name := u.GlobalName
+	if name == "" {
+		name = u.Username
+	}
+
+	data.Claims = &UserClaims{
+		Issuer:            g.APIPath,
+		Subject:           u.ID,
+		Name:              name,
+		PreferredUsername: u.Username,
+		Picture:           u.avatarURL(),
+		Email:             u.Email,
+		EmailVerified:     u.Verified,
+	}
+
+	return &data, nil
+}
+
+// avatarURL returns the url of the avatar on the CDN, animated avatars have a hash starting with a_
+func (u discordUser) avatarURL() string {
+	if u.Avatar == "" {
+		return ""
+	}
+
+	extension := "png"
+	if strings.HasPrefix(u.Avatar, "a_") {
+		extension = "gif"
+	}
+	return discordCDNBase + "/avatars/" + u.ID + "/" + u.Avatar + "." + extension
+}
@@ -0,0 +1,64 @@
+package provider
+
+import (
+	"context"
+	"golang.org/x/oauth2"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDiscordGetUserData(t *testing.T) {
+	tests := []struct {
+		name          string
+		avatar        string
+		expectPicture string
+	}{
+		{name: "static avatar", avatar: "abc", expectPicture: discordCDNBase + "/avatars/80351110224678912/abc.png"},
+		{name: "animated avatar", avatar: "a_abc", expectPicture: discordCDNBase + "/avatars/80351110224678912/a_abc.gif"},
+		{name: "no avatar", avatar: "", expectPicture: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/users/@me" {
+					http.NotFound(w, r)
+					return
+				}
+				writeTestJSON(w, r, map[string]any{
+					"id":          "80351110224678912",
+					"username":    "nelly",
+					"global_name": "Nelly",
+					"avatar":      tt.avatar,
+					"email":       "nelly@discord.test",
+					"verified":    true,
+				})
+			}))
+			defer server.Close()
+
+			p, err := NewDiscordProvider(testProviderConfig(server.URL), "")
+			if err != nil {
+				t.Fatalf("failed to create provider: %v", err)
+			}
+
+			data, err := p.GetUserData(context.Background(), &oauth2.Token{AccessToken: testAccessToken})
+			if err != nil {
+				t.Fatalf("failed to get user data: %v", err)
+			}
+
+			if data.Claims.Subject != "80351110224678912" {
+				t.Errorf("subject = %q, want %q", data.Claims.Subject, "80351110224678912")
+			}
+			if data.Claims.Name != "Nelly" || data.Claims.PreferredUsername != "nelly" {
+				t.Errorf("name = %q, preferred username = %q, want Nelly and nelly", data.Claims.Name, data.Claims.PreferredUsername)
+			}
+			if data.Claims.Picture != tt.expectPicture {
+				t.Errorf("picture = %q, want %q", data.Claims.Picture, tt.expectPicture)
+			}
+			if len(data.Emails) != 1 || data.Emails[0] != (UserEmail{Email: "nelly@discord.test", Verified: true, Primary: true}) {
+				t.Errorf("emails = %+v, want the verified primary email", data.Emails)
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+	"slices"
+	"strconv"
+	"strings"
+	"surge/internal/conf"
+)
+
+// idTokenOnlyClaims identify the user and the issuer, the user info endpoint can't override them in the id token
+var idTokenOnlyClaims = []string{"iss", "sub", "aud"}
+
+type genericOAuth2Provider struct {
+	*oauth2.Config
+
+	oidc         *oidc.Provider
+	clientIDs    []string
+	claimMapping map[string]string
+}
+
+// NewGenericProvider creates a provider of an OpenID Connect issuer declared in the config,
+// the endpoints are discovered from the issuer
+func NewGenericProvider(ctx context.Context, config conf.SurgeOIDCProviderConfiguration, scopes string) (OAuth2Provider, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	oauthScopes := []string{
+		oidc.ScopeOpenID,
+	}
+
+	if len(config.Scopes) > 0 {
+		oauthScopes = append(oauthScopes, config.Scopes...)
+	} else {
+		oauthScopes = append(oauthScopes, "email", "profile")
+	}
+
+	if scopes != "" {
+		oauthScopes = append(oauthScopes, strings.Split(scopes, ",")...)
+	}
+
+	oidcProvider, err := oidc.NewProvider(ctx, config.Issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	return &genericOAuth2Provider{
+		Config: &oauth2.Config{
+			ClientID:     config.ClientID[0],
+			ClientSecret: config.ClientSecret,
+			Endpoint:     oidcProvider.Endpoint(),
+			Scopes:       oauthScopes,
+			RedirectURL:  config.RedirectURI,
+		},
+		oidc:         oidcProvider,
+		clientIDs:    config.ClientID,
+		claimMapping: config.ClaimMapping,
+	}, nil
+}
+
+func (g genericOAuth2Provider) GetOAuthToken(code string) (*oauth2.Token, error) {
+	return g.Exchange(context.Background(), code)
+}
+
+func (g genericOAuth2Provider) GetUserData(ctx context.Context, tok *oauth2.Token) (*UserData, error) {
+	claims := make(map[string]interface{})
+
+	if idToken := tok.Extra("id_token"); idToken != nil {
+		var err error
+		_, claims, err = g.verifyIDToken(ctx, idToken.(string), ParseIDTokenOptions{
+			AccessToken: tok.AccessToken,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Issuers may leave profile claims out of the id token, the user info endpoint has all of them
+	if _, ok := claims["email"]; !ok {
+		userInfo, err := g.oidc.UserInfo(ctx, oauth2.StaticTokenSource(tok))
+		if err != nil {
+			if len(claims) == 0 {
+				return nil, err
+			}
+		} else {
+			// The user info has to be about the user the id token was issued for
+			if subject, ok := claims["sub"]; ok && subject != userInfo.Subject {
+				return nil, errors.New("provider: OIDC user info subject does not match the id token")
+			}
+
+			var userInfoClaims map[string]interface{}
+			if err := userInfo.Claims(&userInfoClaims); err != nil {
+				return nil, err
+			}
+			for key, value := range userInfoClaims {
+				if _, ok := claims[key]; ok && slices.Contains(idTokenOnlyClaims, key) {
+					continue
+				}
+				claims[key] = value
+			}
+		}
+	}
+
+	return mapGenericClaims(claims, g.claimMapping)
+}
+
+func (g genericOAuth2Provider) ParseIDToken(ctx context.Context, idToken string, options ParseIDTokenOptions) (*oidc.IDToken, *UserData, error) {
+	token, claims, err := g.verifyIDToken(ctx, idToken, options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data, err := mapGenericClaims(claims, g.claimMapping)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return token, data, nil
+}
+
+// verifyIDToken verifies the id token was issued to one of the client ids, returning its claims
+func (g genericOAuth2Provider) verifyIDToken(ctx context.Context, idToken string, options ParseIDTokenOptions) (*oidc.IDToken, map[string]interface{}, error) {
+	token, err := g.oidc.VerifierContext(ctx, &oidc.Config{
+		SkipClientIDCheck: true,
+	}).Verify(ctx, idToken)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := verifyAudience(token, g.clientIDs); err != nil {
+		return nil, nil, err
+	}
+
+	if !options.SkipAccessTokenCheck && token.AccessTokenHash != "" {
+		if err := token.VerifyAccessToken(options.AccessToken); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	claims := make(map[string]interface{})
+	if err := token.Claims(&claims); err != nil {
+		return nil, nil, err
+	}
+
+	return token, claims, nil
+}
+
+// mapGenericClaims creates UserData from the claims of an issuer, mapping standard claims to the claims of the issuer
+func mapGenericClaims(claims map[string]interface{}, claimMapping map[string]string) (*UserData, error) {
+	for standard, custom := range claimMapping {
+		if value, ok := claims[custom]; ok {
+			claims[standard] = value
+		}
+	}
+
+	// Some issuers send email_verified as a string
+	if verified, ok := claims["email_verified"].(string); ok {
+		parsed, _ := strconv.ParseBool(verified)
+		claims["email_verified"] = parsed
+	}
+
+	// Audiences of id tokens may be an array, UserClaims only holds a single one
+	if _, ok := claims["aud"].(string); !ok {
+		delete(claims, "aud")
+	}
+
+	marshalled, err := json.Marshal(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	var data UserData
+	if err := json.Unmarshal(marshalled, &data.Claims); err != nil {
+		return nil, err
+	}
+
+	if data.Claims.Subject == "" {
+		return nil, errors.New("provider: OIDC claims must contain a subject")
+	}
+
+	if data.Claims.Email != "" {
+		data.Emails = append(data.Emails, UserEmail{
+			Email:    data.Claims.Email,
+			Verified: data.Claims.EmailVerified,
+			Primary:  true,
+		})
+	}
+
+	return &data, nil
+}
@@ -0,0 +1,164 @@
+package provider
+
+import (
+	"context"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+	"strings"
+	"surge/internal/conf"
+	"testing"
+)
+
+func newTestGenericProvider(t *testing.T, server *mockOIDCServer, claimMapping map[string]string, clientIDs ...string) *genericOAuth2Provider {
+	t.Helper()
+
+	config := conf.SurgeOIDCProviderConfiguration{
+		SurgeProviderConfiguration: testProviderConfig(""),
+		Issuer:                     server.URL,
+		ClaimMapping:               claimMapping,
+	}
+	config.ClientID = append(config.ClientID, clientIDs...)
+
+	p, err := NewGenericProvider(context.Background(), config, "")
+	if err != nil {
+		t.Fatalf("failed to create provider: %v", err)
+	}
+	return p.(*genericOAuth2Provider)
+}
+
+func TestNewGenericProviderDiscovery(t *testing.T) {
+	server := newMockOIDCServer(t)
+	p := newTestGenericProvider(t, server, nil)
+
+	authURL := p.AuthCodeURL("state")
+	if !strings.HasPrefix(authURL, server.URL+"/authorize?") {
+		t.Errorf("auth url = %q, want the discovered authorization endpoint", authURL)
+	}
+	if p.Endpoint.TokenURL != server.URL+"/token" {
+		t.Errorf("token url = %q, want the discovered token endpoint", p.Endpoint.TokenURL)
+	}
+	if !strings.Contains(authURL, "scope=openid+email+profile") {
+		t.Errorf("auth url = %q, want the default scopes", authURL)
+	}
+}
+
+func TestGenericGetUserData(t *testing.T) {
+	server := newMockOIDCServer(t)
+
+	tests := []struct {
+		name         string
+		claimMapping map[string]string
+		idToken      jwt.MapClaims
+		userInfo     map[string]any
+		expectError  bool
+		expectClaims UserClaims
+	}{
+		{
+			name:         "claim mapping",
+			claimMapping: map[string]string{"email": "mail", "preferred_username": "login"},
+			idToken:      jwt.MapClaims{"sub": "user-1", "mail": "mapped@oidc.test", "email_verified": true, "login": "mapped"},
+			expectClaims: UserClaims{Subject: "user-1", Email: "mapped@oidc.test", EmailVerified: true, PreferredUsername: "mapped"},
+		},
+		{
+			name:         "string email_verified true",
+			idToken:      jwt.MapClaims{"sub": "user-1", "email": "user@oidc.test", "email_verified": "true"},
+			expectClaims: UserClaims{Subject: "user-1", Email: "user@oidc.test", EmailVerified: true},
+		},
+		{
+			name:         "string email_verified false",
+			idToken:      jwt.MapClaims{"sub": "user-1", "email": "user@oidc.test", "email_verified": "false"},
+			expectClaims: UserClaims{Subject: "user-1", Email: "user@oidc.test", EmailVerified: false},
+		},
+		{
+			name:         "user info fills missing claims without overriding the id token",
+			idToken:      jwt.MapClaims{"sub": "user-1"},
+			userInfo:     map[string]any{"sub": "user-1", "iss": "https://other.test", "aud": "other-client", "email": "info@oidc.test", "email_verified": true},
+			expectClaims: UserClaims{Subject: "user-1", Email: "info@oidc.test", EmailVerified: true},
+		},
+		{
+			name:        "user info of another user",
+			idToken:     jwt.MapClaims{"sub": "user-1"},
+			userInfo:    map[string]any{"sub": "user-2", "email": "other@oidc.test", "email_verified": true},
+			expectError: true,
+		},
+		{
+			name:        "audience of another client",
+			idToken:     jwt.MapClaims{"sub": "user-1", "aud": "other-client", "email": "user@oidc.test"},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestGenericProvider(t, server, tt.claimMapping)
+			server.userInfo = tt.userInfo
+
+			tok := (&oauth2.Token{AccessToken: testAccessToken}).WithExtra(map[string]any{
+				"id_token": server.signIDToken(t, tt.idToken),
+			})
+
+			data, err := p.GetUserData(context.Background(), tok)
+			if tt.expectError {
+				if err == nil {
+					t.Fatalf("expected an error, got user data %+v", data.Claims)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to get user data: %v", err)
+			}
+
+			if data.Claims.Issuer != server.URL || data.Claims.Aud != testClientID {
+				t.Errorf("issuer = %q, audience = %q, want the ones of the id token", data.Claims.Issuer, data.Claims.Aud)
+			}
+			if data.Claims.Subject != tt.expectClaims.Subject {
+				t.Errorf("subject = %q, want %q", data.Claims.Subject, tt.expectClaims.Subject)
+			}
+			if data.Claims.Email != tt.expectClaims.Email || data.Claims.EmailVerified != tt.expectClaims.EmailVerified {
+				t.Errorf("email = %q verified %v, want %q verified %v", data.Claims.Email, data.Claims.EmailVerified, tt.expectClaims.Email, tt.expectClaims.EmailVerified)
+			}
+			if data.Claims.PreferredUsername != tt.expectClaims.PreferredUsername {
+				t.Errorf("preferred username = %q, want %q", data.Claims.PreferredUsername, tt.expectClaims.PreferredUsername)
+			}
+			if len(data.Emails) != 1 || data.Emails[0].Email != tt.expectClaims.Email || data.Emails[0].Verified != tt.expectClaims.EmailVerified {
+				t.Errorf("emails = %+v, want the email of the claims", data.Emails)
+			}
+		})
+	}
+}
+
+func TestGenericParseIDTokenAudience(t *testing.T) {
+	server := newMockOIDCServer(t)
+	p := newTestGenericProvider(t, server, nil, "ios-client")
+
+	tests := []struct {
+		name        string
+		audience    any
+		expectError bool
+	}{
+		{name: "first client id", audience: testClientID},
+		{name: "additional client id", audience: "ios-client"},
+		{name: "audience list with a client id", audience: []string{"other-client", "ios-client"}},
+		{name: "another client", audience: "other-client", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idToken := server.signIDToken(t, jwt.MapClaims{"sub": "user-1", "aud": tt.audience})
+
+			_, data, err := p.ParseIDToken(context.Background(), idToken, ParseIDTokenOptions{SkipAccessTokenCheck: true})
+			if tt.expectError {
+				if err == nil {
+					t.Fatal("expected the audience to be rejected")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to parse id token: %v", err)
+			}
+			if data.Claims.Subject != "user-1" {
+				t.Errorf("subject = %q, want %q", data.Claims.Subject, "user-1")
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package provider
+
+import (
+	"context"
+	"golang.org/x/oauth2"
+	"strconv"
+	"strings"
+	"surge/internal/conf"
+)
+
+type githubUser struct {
+	ID        int    `json:"id"`
+	Login     string `json:"login"`
+	Name      string `json:"name"`
+	AvatarURL string `json:"avatar_url"`
+	HTMLURL   string `json:"html_url"`
+	Email     string `json:"email"`
+}
+
+type githubUserEmail struct {
+	Email    string `json:"email"`
+	Primary  bool   `json:"primary"`
+	Verified bool   `json:"verified"`
+}
+
+type githubOAuth2Provider struct {
+	*oauth2.Config
+
+	APIHost string
+}
+
+const (
+	defaultGitHubAuthBase = "github.com"
+	defaultGitHubAPIBase  = "api.github.com"
+)
+
+// NewGithubProvider creates a GitHub provider, URL and ApiURL of the config point to GitHub Enterprise if set.
+// ApiURL defaults to the /api/v3 path of URL for GitHub Enterprise.
+func NewGithubProvider(config conf.SurgeProviderConfiguration, scopes string) (OAuth2Provider, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	authHost := chooseHost(config.URL, defaultGitHubAuthBase)
+	apiHost := chooseHost(config.ApiURL, defaultGitHubAPIBase)
+	if config.URL != "" && config.ApiURL == "" {
+		apiHost = authHost + "/api/v3"
+	}
+
+	oauthScopes := []string{
+		"user:email",
+	}
+
+	if scopes != "" {
+		oauthScopes = append(oauthScopes, strings.Split(scopes, ",")...)
+	}
+
+	return &githubOAuth2Provider{
+		Config: &oauth2.Config{
+			ClientID:     config.ClientID[0],
+			ClientSecret: config.ClientSecret,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  authHost + "/login/oauth/authorize",
+				TokenURL: authHost + "/login/oauth/access_token",
+			},
+			Scopes:      oauthScopes,
+			RedirectURL: config.RedirectURI,
+		},
+		APIHost: apiHost,
+	}, nil
+}
+
+func (g githubOAuth2Provider) GetOAuthToken(code string) (*oauth2.Token, error) {
+	return g.Exchange(context.Background(), code)
+}
+
+func (g githubOAuth2Provider) GetUserData(ctx context.Context, tok *oauth2.Token) (*UserData, error) {
+	var u githubUser
+	if err := makeRequest(ctx, tok, g.Config, g.APIHost+"/user", &u); err != nil {
+		return nil, err
+	}
+
+	// The email of the profile is only the public one, the emails endpoint has every email with its verified state
+	var emails []*githubUserEmail
+	if err := makeRequest(ctx, tok, g.Config, g.APIHost+"/user/emails", &emails); err != nil {
+		return nil, err
+	}
+
+	var data UserData
+
+	for _, e := range emails {
+		if e.Email != "" {
+			data.Emails = append(data.Emails, UserEmail{
+				Email:    e.Email,
+				Verified: e.Verified,
+				Primary:  e.Primary,
+			})
+		}
+	}
+
+	data.Claims = &UserClaims{
+		Issuer:            g.APIHost,
+		Subject:           strconv.Itoa(u.ID),
+		Name:              u.Name,
+		PreferredUsername: u.Login,
+		Picture:           u.AvatarURL,
+		Profile:           u.HTMLURL,
+	}
+
+	for _, e := range data.Emails {
+		if e.Primary {
+			data.Claims.Email = e.Email
+			data.Claims.EmailVerified = e.Verified
+			break
+		}
+	}
+
+	return &data, nil
+}
@@ -0,0 +1,113 @@
+package provider
+
+import (
+	"context"
+	"golang.org/x/oauth2"
+	"strconv"
+	"strings"
+	"surge/internal/conf"
+)
+
+type gitlabUser struct {
+	ID          int     `json:"id"`
+	Username    string  `json:"username"`
+	Name        string  `json:"name"`
+	AvatarURL   string  `json:"avatar_url"`
+	WebURL      string  `json:"web_url"`
+	Email       string  `json:"email"`
+	ConfirmedAt *string `json:"confirmed_at"`
+}
+
+type gitlabUserEmail struct {
+	Email       string  `json:"email"`
+	ConfirmedAt *string `json:"confirmed_at"`
+}
+
+type gitlabOAuth2Provider struct {
+	*oauth2.Config
+
+	Host string
+}
+
+const defaultGitLabAuthBase = "gitlab.com"
+
+// NewGitlabProvider creates a GitLab provider, URL of the config points to a self-hosted instance if set
+func NewGitlabProvider(config conf.SurgeProviderConfiguration, scopes string) (OAuth2Provider, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	host := chooseHost(config.URL, defaultGitLabAuthBase)
+
+	oauthScopes := []string{
+		"read_user",
+	}
+
+	if scopes != "" {
+		oauthScopes = append(oauthScopes, strings.Split(scopes, ",")...)
+	}
+
+	return &gitlabOAuth2Provider{
+		Config: &oauth2.Config{
+			ClientID:     config.ClientID[0],
+			ClientSecret: config.ClientSecret,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  host + "/oauth/authorize",
+				TokenURL: host + "/oauth/token",
+			},
+			Scopes:      oauthScopes,
+			RedirectURL: config.RedirectURI,
+		},
+		Host: host,
+	}, nil
+}
+
+func (g gitlabOAuth2Provider) GetOAuthToken(code string) (*oauth2.Token, error) {
+	return g.Exchange(context.Background(), code)
+}
+
+func (g gitlabOAuth2Provider) GetUserData(ctx context.Context, tok *oauth2.Token) (*UserData, error) {
+	var u gitlabUser
+	if err := makeRequest(ctx, tok, g.Config, g.Host+"/api/v4/user", &u); err != nil {
+		return nil, err
+	}
+
+	// The primary email is confirmed if the user is, secondary emails are confirmed one by one
+	var emails []*gitlabUserEmail
+	if err := makeRequest(ctx, tok, g.Config, g.Host+"/api/v4/user/emails", &emails); err != nil {
+		return nil, err
+	}
+
+	var data UserData
+
+	if u.Email != "" {
+		data.Emails = append(data.Emails, UserEmail{
+			Email:    u.Email,
+			Verified: u.ConfirmedAt != nil,
+			Primary:  true,
+		})
+	}
+
+	for _, e := range emails {
+		if e.Email != "" && !strings.EqualFold(e.Email, u.Email) {
+			data.Emails = append(data.Emails, UserEmail{
+				Email:    e.Email,
+				Verified: e.ConfirmedAt != nil,
+				Primary:  false,
+			})
+		}
+	}
+
+	data.Claims = &UserClaims{
+		Issuer:            g.Host,
+		Subject:           strconv.Itoa(u.ID),
+		Name:              u.Name,
+		PreferredUsername: u.Username,
+		Picture:           u.AvatarURL,
+		Profile:           u.WebURL,
+		Email:             u.Email,
+		EmailVerified:     u.ConfirmedAt != nil,
+	}
+
+	return &data, nil
+}
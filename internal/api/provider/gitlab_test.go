@@ -0,0 +1,88 @@
+package provider
+
+import (
+	"context"
+	"golang.org/x/oauth2"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestGitlabGetUserData(t *testing.T) {
+	confirmedAt := "2024-01-01T00:00:00.000Z"
+
+	tests := []struct {
+		name         string
+		confirmedAt  *string
+		expectEmails []UserEmail
+	}{
+		{
+			name:        "confirmed user",
+			confirmedAt: &confirmedAt,
+			expectEmails: []UserEmail{
+				{Email: "primary@gitlab.test", Verified: true, Primary: true},
+				{Email: "confirmed@gitlab.test", Verified: true, Primary: false},
+				{Email: "unconfirmed@gitlab.test", Verified: false, Primary: false},
+			},
+		},
+		{
+			name:        "unconfirmed user",
+			confirmedAt: nil,
+			expectEmails: []UserEmail{
+				{Email: "primary@gitlab.test", Verified: false, Primary: true},
+				{Email: "confirmed@gitlab.test", Verified: true, Primary: false},
+				{Email: "unconfirmed@gitlab.test", Verified: false, Primary: false},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/api/v4/user":
+					writeTestJSON(w, r, map[string]any{
+						"id":           42,
+						"username":     "jdoe",
+						"name":         "Jane Doe",
+						"avatar_url":   "https://gitlab.test/avatar.png",
+						"web_url":      "https://gitlab.test/jdoe",
+						"email":        "primary@gitlab.test",
+						"confirmed_at": tt.confirmedAt,
+					})
+				case "/api/v4/user/emails":
+					// The primary email is listed as well and must not be added twice
+					writeTestJSON(w, r, []map[string]any{
+						{"email": "PRIMARY@gitlab.test", "confirmed_at": confirmedAt},
+						{"email": "confirmed@gitlab.test", "confirmed_at": confirmedAt},
+						{"email": "unconfirmed@gitlab.test", "confirmed_at": nil},
+					})
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			defer server.Close()
+
+			p, err := NewGitlabProvider(testProviderConfig(server.URL), "")
+			if err != nil {
+				t.Fatalf("failed to create provider: %v", err)
+			}
+
+			data, err := p.GetUserData(context.Background(), &oauth2.Token{AccessToken: testAccessToken})
+			if err != nil {
+				t.Fatalf("failed to get user data: %v", err)
+			}
+
+			if data.Claims.Subject != "42" {
+				t.Errorf("subject = %q, want %q", data.Claims.Subject, "42")
+			}
+			if data.Claims.Email != "primary@gitlab.test" || data.Claims.EmailVerified != (tt.confirmedAt != nil) {
+				t.Errorf("email = %q verified %v, want primary@gitlab.test verified %v", data.Claims.Email, data.Claims.EmailVerified, tt.confirmedAt != nil)
+			}
+			if !reflect.DeepEqual(data.Emails, tt.expectEmails) {
+				t.Errorf("emails = %+v, want %+v", data.Emails, tt.expectEmails)
+			}
+		})
+	}
+}
@@ -24,7 +24,8 @@ type googleUser struct {
 type googleOAuth2Provider struct {
 	*oauth2.Config
 
-	oidc *oidc.Provider
+	oidc      *oidc.Provider
+	clientIDs []string
 }
 
 const IssuerGoogle = "https://accounts.google.com"
@@ -58,7 +59,8 @@ func NewGoogleProvider(ctx context.Context, config conf.SurgeProviderConfigurati
 			Scopes:       oauthScopes,
 			RedirectURL:  config.RedirectURI,
 		},
-		oidc: oidcProvider,
+		oidc:      oidcProvider,
+		clientIDs: config.ClientID,
 	}, nil
 }
 
@@ -116,6 +118,19 @@ func (g googleOAuth2Provider) GetUserData(ctx context.Context, tok *oauth2.Token
 	return &data, nil
 }
 
+func (g googleOAuth2Provider) ParseIDToken(ctx context.Context, idToken string, options ParseIDTokenOptions) (*oidc.IDToken, *UserData, error) {
+	token, data, err := ParseIDToken(ctx, g.oidc, nil, idToken, options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := verifyAudience(token, g.clientIDs); err != nil {
+		return nil, nil, err
+	}
+
+	return token, data, nil
+}
+
 func (u googleUser) IsEmailVerified() bool {
 	return u.VerifiedEmail || u.EmailVerified
 }
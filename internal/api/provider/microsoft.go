@@ -0,0 +1,261 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"surge/internal/conf"
+	"surge/internal/utilities"
+)
+
+const defaultMicrosoftAuthBase = "login.microsoftonline.com"
+
+// microsoftConsumerTenant is the tenant of personal Microsoft accounts, which have their emails verified by Microsoft
+const microsoftConsumerTenant = "9188040d-6c67-4c5b-b112-36a304b66dad"
+
+type microsoftUser struct {
+	Issuer            string       `json:"iss"`
+	Subject           string       `json:"sub"`
+	TenantID          string       `json:"tid"`
+	Name              string       `json:"name"`
+	GivenName         string       `json:"given_name"`
+	FamilyName        string       `json:"family_name"`
+	PreferredUsername string       `json:"preferred_username"`
+	Email             string       `json:"email"`
+	EmailDomainOwner  flexibleBool `json:"xms_edov"`
+}
+
+type microsoftOAuth2Provider struct {
+	*oauth2.Config
+
+	oidc           *oidc.Provider
+	clientIDs      []string
+	host           string
+	tenant         string
+	allowedTenants []string
+}
+
+// NewMicrosoftProvider creates a provider of the Microsoft identity platform for the tenant of the config.
+// Tokens of the common and organizations tenants are issued by the tenant of the user,
+// so the issuer is checked against the tid claim instead of the discovered issuer.
+func NewMicrosoftProvider(ctx context.Context, config conf.SurgeMicrosoftProviderConfiguration, scopes string) (OAuth2Provider, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	host := chooseHost(config.URL, defaultMicrosoftAuthBase)
+	tenant := config.Tenant
+	switch tenant {
+	case "":
+		tenant = "common"
+	case "common", "organizations", "consumers":
+	default:
+		// Tenants configured by domain are resolved to their id, which the tid claim of tokens is compared with
+		if !isMicrosoftTenantID(tenant) {
+			tenantID, err := resolveMicrosoftTenantID(ctx, host, tenant)
+			if err != nil {
+				return nil, err
+			}
+			tenant = tenantID
+		}
+	}
+	tenantHost := host + "/" + tenant
+
+	oauthScopes := []string{
+		oidc.ScopeOpenID,
+		"email",
+		"profile",
+	}
+
+	if scopes != "" {
+		oauthScopes = append(oauthScopes, strings.Split(scopes, ",")...)
+	}
+
+	oidcProvider := (&oidc.ProviderConfig{
+		IssuerURL: tenantHost + "/v2.0",
+		AuthURL:   tenantHost + "/oauth2/v2.0/authorize",
+		TokenURL:  tenantHost + "/oauth2/v2.0/token",
+		JWKSURL:   tenantHost + "/discovery/v2.0/keys",
+	}).NewProvider(ctx)
+
+	return &microsoftOAuth2Provider{
+		Config: &oauth2.Config{
+			ClientID:     config.ClientID[0],
+			ClientSecret: config.ClientSecret,
+			Endpoint:     oidcProvider.Endpoint(),
+			Scopes:       oauthScopes,
+			RedirectURL:  config.RedirectURI,
+		},
+		oidc:           oidcProvider,
+		clientIDs:      config.ClientID,
+		host:           host,
+		tenant:         tenant,
+		allowedTenants: config.AllowedTenants,
+	}, nil
+}
+
+func (g microsoftOAuth2Provider) GetOAuthToken(code string) (*oauth2.Token, error) {
+	return g.Exchange(context.Background(), code)
+}
+
+func (g microsoftOAuth2Provider) GetUserData(ctx context.Context, tok *oauth2.Token) (*UserData, error) {
+	idToken := tok.Extra("id_token")
+	if idToken == nil {
+		return nil, fmt.Errorf("provider: microsoft did not return an id token")
+	}
+
+	_, data, err := g.ParseIDToken(ctx, idToken.(string), ParseIDTokenOptions{
+		AccessToken: tok.AccessToken,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func (g microsoftOAuth2Provider) ParseIDToken(ctx context.Context, idToken string, options ParseIDTokenOptions) (*oidc.IDToken, *UserData, error) {
+	token, err := g.oidc.VerifierContext(ctx, &oidc.Config{
+		SkipClientIDCheck: true,
+		SkipIssuerCheck:   true,
+	}).Verify(ctx, idToken)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := verifyAudience(token, g.clientIDs); err != nil {
+		return nil, nil, err
+	}
+
+	if !options.SkipAccessTokenCheck && token.AccessTokenHash != "" {
+		if err := token.VerifyAccessToken(options.AccessToken); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var claims microsoftUser
+	if err := token.Claims(&claims); err != nil {
+		return nil, nil, err
+	}
+
+	if err := g.validateTenant(claims); err != nil {
+		return nil, nil, err
+	}
+
+	return token, claims.userData(), nil
+}
+
+// validateTenant checks the token was issued by the tenant of the user, which has to be allowed
+func (g microsoftOAuth2Provider) validateTenant(claims microsoftUser) error {
+	if claims.TenantID == "" {
+		return fmt.Errorf("provider: microsoft id token has no tenant")
+	}
+	if claims.Issuer != g.host+"/"+claims.TenantID+"/v2.0" {
+		return fmt.Errorf("provider: microsoft id token issuer %q does not match tenant %q", claims.Issuer, claims.TenantID)
+	}
+
+	switch g.tenant {
+	case "common":
+	case "organizations":
+		if strings.EqualFold(claims.TenantID, microsoftConsumerTenant) {
+			return fmt.Errorf("provider: microsoft personal accounts are not allowed for organizations")
+		}
+	case "consumers":
+		if !strings.EqualFold(claims.TenantID, microsoftConsumerTenant) {
+			return fmt.Errorf("provider: microsoft tenant %q is not the personal accounts tenant", claims.TenantID)
+		}
+	default:
+		if !strings.EqualFold(g.tenant, claims.TenantID) {
+			return fmt.Errorf("provider: microsoft tenant %q is not the configured tenant", claims.TenantID)
+		}
+	}
+
+	if len(g.allowedTenants) > 0 && !slices.ContainsFunc(g.allowedTenants, func(tenant string) bool {
+		return strings.EqualFold(tenant, claims.TenantID)
+	}) {
+		return fmt.Errorf("provider: microsoft tenant %q is not allowed", claims.TenantID)
+	}
+
+	return nil
+}
+
+// userData maps the claims to UserData. Tenants can set any email on their users, so the email is only verified if
+// the tenant owns the domain of the email (xms_edov) or it is the email of a personal Microsoft account.
+func (u microsoftUser) userData() *UserData {
+	var data UserData
+
+	email := u.Email
+	verified := bool(u.EmailDomainOwner) || u.TenantID == microsoftConsumerTenant
+	if email == "" && strings.Contains(u.PreferredUsername, "@") {
+		// The user principal name looks like an email but is not necessarily one that receives mail
+		email = u.PreferredUsername
+		verified = false
+	}
+
+	if email != "" {
+		data.Emails = append(data.Emails, UserEmail{
+			Email:    email,
+			Verified: verified,
+			Primary:  true,
+		})
+	}
+
+	data.Claims = &UserClaims{
+		Issuer:     u.Issuer,
+		Subject:    u.Subject,
+		Name:       u.Name,
+		GivenName:  u.GivenName,
+		FamilyName: u.FamilyName,
+		ExtraClaims: map[string]any{
+			"tid": u.TenantID,
+		},
+	}
+
+	return &data
+}
+
+// isMicrosoftTenantID reports whether the tenant is an id rather than a domain
+// resolveMicrosoftTenantID looks up the id of the tenant owning domain, from the issuer of its OpenID configuration
+func resolveMicrosoftTenantID(ctx context.Context, host string, domain string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, host+"/"+url.PathEscape(domain)+"/v2.0/.well-known/openid-configuration", nil)
+	if err != nil {
+		return "", err
+	}
+
+	client := &http.Client{Timeout: defaultTimeout}
+	res, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer utilities.SafeClose(res.Body)
+
+	if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(res.Body)
+		return "", newHttpError(res.StatusCode, string(body))
+	}
+
+	var configuration struct {
+		Issuer string `json:"issuer"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&configuration); err != nil {
+		return "", err
+	}
+
+	tenantID := strings.TrimSuffix(strings.TrimPrefix(configuration.Issuer, host+"/"), "/v2.0")
+	if !isMicrosoftTenantID(tenantID) {
+		return "", fmt.Errorf("provider: microsoft tenant %q has an unexpected issuer %q", domain, configuration.Issuer)
+	}
+
+	return tenantID, nil
+}
+
+func isMicrosoftTenantID(tenant string) bool {
+	return len(tenant) == 36 && strings.Count(tenant, "-") == 4
+}
@@ -0,0 +1,137 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"surge/internal/conf"
+	"testing"
+)
+
+func TestMicrosoftValidateTenant(t *testing.T) {
+	const (
+		host           = "https://login.microsoftonline.com"
+		companyTenant  = "72f988bf-86f1-41af-91ab-2d7cd011db47"
+		anotherTenant  = "0b4b2ac4-9d2a-4b1e-9d3c-5a6b7c8d9e0f"
+		companyIssuer  = host + "/" + companyTenant + "/v2.0"
+		consumerIssuer = host + "/" + microsoftConsumerTenant + "/v2.0"
+	)
+
+	tests := []struct {
+		name           string
+		tenant         string
+		allowedTenants []string
+		claims         microsoftUser
+		expectError    bool
+	}{
+		{
+			name:   "common accepts organization accounts",
+			tenant: "common",
+			claims: microsoftUser{Issuer: companyIssuer, TenantID: companyTenant},
+		},
+		{
+			name:   "common accepts personal accounts",
+			tenant: "common",
+			claims: microsoftUser{Issuer: consumerIssuer, TenantID: microsoftConsumerTenant},
+		},
+		{
+			name:   "organizations accepts organization accounts",
+			tenant: "organizations",
+			claims: microsoftUser{Issuer: companyIssuer, TenantID: companyTenant},
+		},
+		{
+			name:        "organizations rejects personal accounts",
+			tenant:      "organizations",
+			claims:      microsoftUser{Issuer: consumerIssuer, TenantID: microsoftConsumerTenant},
+			expectError: true,
+		},
+		{
+			name:   "consumers accepts personal accounts",
+			tenant: "consumers",
+			claims: microsoftUser{Issuer: consumerIssuer, TenantID: microsoftConsumerTenant},
+		},
+		{
+			name:        "consumers rejects organization accounts",
+			tenant:      "consumers",
+			claims:      microsoftUser{Issuer: companyIssuer, TenantID: companyTenant},
+			expectError: true,
+		},
+		{
+			name:        "tenant id rejects other tenants",
+			tenant:      anotherTenant,
+			claims:      microsoftUser{Issuer: companyIssuer, TenantID: companyTenant},
+			expectError: true,
+		},
+		{
+			name:        "issuer of another tenant",
+			tenant:      "common",
+			claims:      microsoftUser{Issuer: consumerIssuer, TenantID: companyTenant},
+			expectError: true,
+		},
+		{
+			name:           "tenant not in allowed tenants",
+			tenant:         "organizations",
+			allowedTenants: []string{anotherTenant},
+			claims:         microsoftUser{Issuer: companyIssuer, TenantID: companyTenant},
+			expectError:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := microsoftOAuth2Provider{host: host, tenant: tt.tenant, allowedTenants: tt.allowedTenants}
+
+			err := p.validateTenant(tt.claims)
+			if tt.expectError && err == nil {
+				t.Fatal("expected the tenant to be rejected")
+			}
+			if !tt.expectError && err != nil {
+				t.Fatalf("failed to validate tenant: %v", err)
+			}
+		})
+	}
+}
+
+func TestMicrosoftDomainTenant(t *testing.T) {
+	const (
+		companyTenant = "72f988bf-86f1-41af-91ab-2d7cd011db47"
+		foreignTenant = "0b4b2ac4-9d2a-4b1e-9d3c-5a6b7c8d9e0f"
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/contoso.test/v2.0/.well-known/openid-configuration" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"issuer": "http://" + r.Host + "/" + companyTenant + "/v2.0"})
+	}))
+	defer server.Close()
+
+	config := conf.SurgeMicrosoftProviderConfiguration{
+		SurgeProviderConfiguration: testProviderConfig(server.URL),
+		Tenant:                     "contoso.test",
+	}
+
+	p, err := NewMicrosoftProvider(context.Background(), config, "")
+	if err != nil {
+		t.Fatalf("failed to create provider: %v", err)
+	}
+	g := p.(*microsoftOAuth2Provider)
+
+	if g.tenant != companyTenant {
+		t.Fatalf("tenant = %q, want the id of the domain %q", g.tenant, companyTenant)
+	}
+	if err := g.validateTenant(microsoftUser{Issuer: server.URL + "/" + companyTenant + "/v2.0", TenantID: companyTenant}); err != nil {
+		t.Errorf("failed to validate the tenant of the domain: %v", err)
+	}
+	if err := g.validateTenant(microsoftUser{Issuer: server.URL + "/" + foreignTenant + "/v2.0", TenantID: foreignTenant}); err == nil {
+		t.Error("expected a foreign tenant to be rejected")
+	}
+
+	config.Tenant = "unknown.test"
+	if _, err := NewMicrosoftProvider(context.Background(), config, ""); err == nil {
+		t.Error("expected an unknown domain to fail creating the provider")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"github.com/coreos/go-oidc/v3/oidc"
+	"slices"
 )
 
 type ParseIDTokenOptions struct {
@@ -31,6 +32,8 @@ func ParseIDToken(ctx context.Context, provider *oidc.Provider, config *oidc.Con
 	switch token.Issuer {
 	case IssuerGoogle:
 		token, data, err = parseGoogleIDToken(token)
+	case IssuerApple:
+		token, data, err = parseAppleIDToken(token)
 	default:
 		token, data, err = parseGenericIDToken(token)
 	}
@@ -48,6 +51,16 @@ func ParseIDToken(ctx context.Context, provider *oidc.Provider, config *oidc.Con
 	return token, data, nil
 }
 
+// verifyAudience checks the id token was issued to one of the client ids
+func verifyAudience(token *oidc.IDToken, clientIDs []string) error {
+	for _, audience := range token.Audience {
+		if slices.Contains(clientIDs, audience) {
+			return nil
+		}
+	}
+	return fmt.Errorf("provider: id token audience %v is not one of the configured client ids", token.Audience)
+}
+
 //
 // Start ID Token Parsers
 //
@@ -105,3 +118,32 @@ func parseGoogleIDToken(token *oidc.IDToken) (*oidc.IDToken, *UserData, error) {
 
 	return token, &data, nil
 }
+
+func parseAppleIDToken(token *oidc.IDToken) (*oidc.IDToken, *UserData, error) {
+	var claims appleUser
+	if err := token.Claims(&claims); err != nil {
+		return nil, nil, err
+	}
+
+	var data UserData
+
+	isPrivateEmail := bool(claims.IsPrivateEmail) || IsApplePrivateRelayEmail(claims.Email)
+
+	if claims.Email != "" {
+		data.Emails = append(data.Emails, UserEmail{
+			Email:    claims.Email,
+			Verified: bool(claims.EmailVerified),
+			Primary:  true,
+		})
+	}
+
+	data.Claims = &UserClaims{
+		Issuer:  claims.Issuer,
+		Subject: claims.Subject,
+		ExtraClaims: map[string]any{
+			"is_private_email": isPrivateEmail,
+		},
+	}
+
+	return token, &data, nil
+}
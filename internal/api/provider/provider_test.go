@@ -0,0 +1,126 @@
+package provider
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/golang-jwt/jwt/v5"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"surge/internal/conf"
+	"testing"
+	"time"
+)
+
+const (
+	testClientID     = "test-client"
+	testClientSecret = "test-secret"
+	testAccessToken  = "test-access-token"
+	testKeyID        = "test-key"
+)
+
+// testProviderConfig returns a provider configuration pointing to the mock server at url
+func testProviderConfig(url string) conf.SurgeProviderConfiguration {
+	return conf.SurgeProviderConfiguration{
+		ClientID:     []string{testClientID},
+		ClientSecret: testClientSecret,
+		RedirectURI:  "https://surge.test/callback",
+		URL:          url,
+		Enabled:      true,
+	}
+}
+
+// writeTestJSON writes value as the JSON response, failing the request if it isn't authorized with testAccessToken
+func writeTestJSON(w http.ResponseWriter, r *http.Request, value any) {
+	if r.Header.Get("Authorization") != "Bearer "+testAccessToken {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+// mockOIDCServer is an OpenID Connect issuer with discovery, keys and user info, signing id tokens with its own key
+type mockOIDCServer struct {
+	*httptest.Server
+
+	key *rsa.PrivateKey
+	// userInfo is served by the user info endpoint
+	userInfo map[string]any
+}
+
+func newMockOIDCServer(t *testing.T) *mockOIDCServer {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	s := &mockOIDCServer{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                                s.URL,
+			"authorization_endpoint":                s.URL + "/authorize",
+			"token_endpoint":                        s.URL + "/token",
+			"userinfo_endpoint":                     s.URL + "/userinfo",
+			"jwks_uri":                              s.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"keys": []map[string]any{{
+				"kty": "RSA",
+				"kid": testKeyID,
+				"alg": "RS256",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if s.userInfo == nil {
+			http.NotFound(w, r)
+			return
+		}
+		writeTestJSON(w, r, s.userInfo)
+	})
+
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// signIDToken signs an id token of the issuer for testClientID, claims override the defaults
+func (s *mockOIDCServer) signIDToken(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+
+	tokenClaims := jwt.MapClaims{
+		"iss": s.URL,
+		"aud": testClientID,
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	for key, value := range claims {
+		tokenClaims[key] = value
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, tokenClaims)
+	token.Header["kid"] = testKeyID
+
+	signed, err := token.SignedString(s.key)
+	if err != nil {
+		t.Fatalf("failed to sign id token: %v", err)
+	}
+	return signed
+}
@@ -14,7 +14,24 @@ func Provider(ctx context.Context, config *conf.SurgeConfigurations, name string
 	switch name {
 	case "google":
 		return NewGoogleProvider(ctx, config.External.Google, scopes)
+	case "apple":
+		return NewAppleProvider(ctx, config.External.Apple, scopes)
+	case "microsoft":
+		return NewMicrosoftProvider(ctx, config.External.Microsoft, scopes)
+	case "github":
+		return NewGithubProvider(config.External.Github, scopes)
+	case "gitlab":
+		return NewGitlabProvider(config.External.Gitlab, scopes)
+	case "discord":
+		return NewDiscordProvider(config.External.Discord, scopes)
+	case "twitch":
+		return NewTwitchProvider(config.External.Twitch, scopes)
+	case "slack":
+		return NewSlackProvider(ctx, config.External.Slack, scopes)
 	default:
+		if oidcConfig, ok := config.External.OIDC[name]; ok {
+			return NewGenericProvider(ctx, oidcConfig, scopes)
+		}
 		return nil, fmt.Errorf("provider %s could not be found", name)
 	}
 }
@@ -0,0 +1,93 @@
+package provider
+
+import (
+	"context"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+	"strings"
+	"surge/internal/conf"
+)
+
+type slackOAuth2Provider struct {
+	*oauth2.Config
+
+	oidc *oidc.Provider
+}
+
+const defaultSlackAuthBase = "slack.com"
+
+// NewSlackProvider creates a Sign in with Slack provider, which is OpenID Connect discovered from URL of the config
+func NewSlackProvider(ctx context.Context, config conf.SurgeProviderConfiguration, scopes string) (OAuth2Provider, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	oauthScopes := []string{
+		oidc.ScopeOpenID,
+		"email",
+		"profile",
+	}
+
+	if scopes != "" {
+		oauthScopes = append(oauthScopes, strings.Split(scopes, ",")...)
+	}
+
+	oidcProvider, err := oidc.NewProvider(ctx, chooseHost(config.URL, defaultSlackAuthBase))
+	if err != nil {
+		return nil, err
+	}
+
+	return &slackOAuth2Provider{
+		Config: &oauth2.Config{
+			ClientID:     config.ClientID[0],
+			ClientSecret: config.ClientSecret,
+			Endpoint:     oidcProvider.Endpoint(),
+			Scopes:       oauthScopes,
+			RedirectURL:  config.RedirectURI,
+		},
+		oidc: oidcProvider,
+	}, nil
+}
+
+func (g slackOAuth2Provider) GetOAuthToken(code string) (*oauth2.Token, error) {
+	return g.Exchange(context.Background(), code)
+}
+
+func (g slackOAuth2Provider) GetUserData(ctx context.Context, tok *oauth2.Token) (*UserData, error) {
+	var claims map[string]interface{}
+
+	idToken := tok.Extra("id_token")
+	if idToken == nil {
+		// Slack returns an id token for the openid scope, the user info endpoint has the same claims
+		userInfo, err := g.oidc.UserInfo(ctx, oauth2.StaticTokenSource(tok))
+		if err != nil {
+			return nil, err
+		}
+		if err := userInfo.Claims(&claims); err != nil {
+			return nil, err
+		}
+	} else {
+		token, err := g.oidc.VerifierContext(ctx, &oidc.Config{
+			ClientID: g.Config.ClientID,
+		}).Verify(ctx, idToken.(string))
+		if err != nil {
+			return nil, err
+		}
+		if err := token.Claims(&claims); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := mapGenericClaims(claims, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if teamID, ok := claims["https://slack.com/team_id"]; ok {
+		data.Claims.ExtraClaims = map[string]any{
+			"team_id": teamID,
+		}
+	}
+
+	return data, nil
+}
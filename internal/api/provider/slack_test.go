@@ -0,0 +1,69 @@
+package provider
+
+import (
+	"context"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+	"testing"
+)
+
+func TestSlackGetUserData(t *testing.T) {
+	server := newMockOIDCServer(t)
+
+	p, err := NewSlackProvider(context.Background(), testProviderConfig(server.URL), "")
+	if err != nil {
+		t.Fatalf("failed to create provider: %v", err)
+	}
+
+	claims := map[string]any{
+		"sub":                       "U0R7JM",
+		"email":                     "krane@slack.test",
+		"email_verified":            true,
+		"name":                      "Krane",
+		"picture":                   "https://slack.test/avatar.png",
+		"https://slack.com/team_id": "T0R7GR",
+	}
+
+	tests := []struct {
+		name  string
+		token func() *oauth2.Token
+	}{
+		{
+			name: "id token",
+			token: func() *oauth2.Token {
+				server.userInfo = nil
+				tok := &oauth2.Token{AccessToken: testAccessToken}
+				return tok.WithExtra(map[string]any{"id_token": server.signIDToken(t, jwt.MapClaims(claims))})
+			},
+		},
+		{
+			name: "user info",
+			token: func() *oauth2.Token {
+				server.userInfo = claims
+				return &oauth2.Token{AccessToken: testAccessToken}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := p.GetUserData(context.Background(), tt.token())
+			if err != nil {
+				t.Fatalf("failed to get user data: %v", err)
+			}
+
+			if data.Claims.Subject != "U0R7JM" {
+				t.Errorf("subject = %q, want %q", data.Claims.Subject, "U0R7JM")
+			}
+			if data.Claims.Name != "Krane" || data.Claims.Picture != "https://slack.test/avatar.png" {
+				t.Errorf("name = %q, picture = %q, want the claims of the user", data.Claims.Name, data.Claims.Picture)
+			}
+			if len(data.Emails) != 1 || data.Emails[0] != (UserEmail{Email: "krane@slack.test", Verified: true, Primary: true}) {
+				t.Errorf("emails = %+v, want the verified primary email", data.Emails)
+			}
+			if data.Claims.ExtraClaims["team_id"] != "T0R7GR" {
+				t.Errorf("team id = %v, want %q", data.Claims.ExtraClaims["team_id"], "T0R7GR")
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"golang.org/x/oauth2"
+	"net/http"
+	"strings"
+	"surge/internal/conf"
+)
+
+type twitchUser struct {
+	ID              string `json:"id"`
+	Login           string `json:"login"`
+	DisplayName     string `json:"display_name"`
+	ProfileImageURL string `json:"profile_image_url"`
+	Email           string `json:"email"`
+}
+
+type twitchUsers struct {
+	Data []twitchUser `json:"data"`
+}
+
+type twitchOAuth2Provider struct {
+	*oauth2.Config
+
+	APIHost string
+}
+
+const (
+	defaultTwitchAuthBase = "id.twitch.tv"
+	defaultTwitchAPIBase  = "api.twitch.tv"
+)
+
+// NewTwitchProvider creates a Twitch provider, URL and ApiURL of the config override the hosts, e.g. for a mock server
+func NewTwitchProvider(config conf.SurgeProviderConfiguration, scopes string) (OAuth2Provider, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	authHost := chooseHost(config.URL, defaultTwitchAuthBase)
+	apiHost := chooseHost(config.ApiURL, defaultTwitchAPIBase)
+
+	oauthScopes := []string{
+		"user:read:email",
+	}
+
+	if scopes != "" {
+		oauthScopes = append(oauthScopes, strings.Split(scopes, ",")...)
+	}
+
+	return &twitchOAuth2Provider{
+		Config: &oauth2.Config{
+			ClientID:     config.ClientID[0],
+			ClientSecret: config.ClientSecret,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:   authHost + "/oauth2/authorize",
+				TokenURL:  authHost + "/oauth2/token",
+				AuthStyle: oauth2.AuthStyleInParams,
+			},
+			Scopes:      oauthScopes,
+			RedirectURL: config.RedirectURI,
+		},
+		APIHost: apiHost,
+	}, nil
+}
+
+func (g twitchOAuth2Provider) GetOAuthToken(code string) (*oauth2.Token, error) {
+	return g.Exchange(context.Background(), code)
+}
+
+func (g twitchOAuth2Provider) GetUserData(ctx context.Context, tok *oauth2.Token) (*UserData, error) {
+	// The Helix API requires the client id of the token next to the token itself
+	header := http.Header{}
+	header.Set("Client-Id", g.Config.ClientID)
+
+	var users twitchUsers
+	if err := makeRequestWithHeader(ctx, tok, g.Config, g.APIHost+"/helix/users", header, &users); err != nil {
+		return nil, err
+	}
+	if len(users.Data) == 0 {
+		return nil, fmt.Errorf("provider: twitch did not return the user")
+	}
+	u := users.Data[0]
+
+	var data UserData
+
+	// Twitch only returns the email if the user verified it
+	if u.Email != "" {
+		data.Emails = append(data.Emails, UserEmail{
+			Email:    u.Email,
+			Verified: true,
+			Primary:  true,
+		})
+	}
+
+	data.Claims = &UserClaims{
+		Issuer:            g.APIHost,
+		Subject:           u.ID,
+		Name:              u.DisplayName,
+		PreferredUsername: u.Login,
+		Picture:           u.ProfileImageURL,
+		Email:             u.Email,
+		EmailVerified:     u.Email != "",
+	}
+
+	return &data, nil
+}
@@ -0,0 +1,58 @@
+package provider
+
+import (
+	"context"
+	"golang.org/x/oauth2"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTwitchGetUserData(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/helix/users" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Client-Id") != testClientID {
+			http.Error(w, "client id header missing", http.StatusUnauthorized)
+			return
+		}
+		writeTestJSON(w, r, map[string]any{
+			"data": []map[string]any{{
+				"id":                "141981764",
+				"login":             "twitchdev",
+				"display_name":      "TwitchDev",
+				"profile_image_url": "https://twitch.test/profile.png",
+				"email":             "dev@twitch.test",
+			}},
+		})
+	}))
+	defer server.Close()
+
+	config := testProviderConfig(server.URL)
+	config.ApiURL = server.URL
+
+	p, err := NewTwitchProvider(config, "")
+	if err != nil {
+		t.Fatalf("failed to create provider: %v", err)
+	}
+
+	data, err := p.GetUserData(context.Background(), &oauth2.Token{AccessToken: testAccessToken})
+	if err != nil {
+		t.Fatalf("failed to get user data: %v", err)
+	}
+
+	if data.Claims.Subject != "141981764" {
+		t.Errorf("subject = %q, want %q", data.Claims.Subject, "141981764")
+	}
+	if data.Claims.Name != "TwitchDev" || data.Claims.PreferredUsername != "twitchdev" {
+		t.Errorf("name = %q, preferred username = %q, want TwitchDev and twitchdev", data.Claims.Name, data.Claims.PreferredUsername)
+	}
+	if data.Claims.Picture != "https://twitch.test/profile.png" {
+		t.Errorf("picture = %q, want %q", data.Claims.Picture, "https://twitch.test/profile.png")
+	}
+	if len(data.Emails) != 1 || data.Emails[0] != (UserEmail{Email: "dev@twitch.test", Verified: true, Primary: true}) {
+		t.Errorf("emails = %+v, want the verified primary email", data.Emails)
+	}
+}
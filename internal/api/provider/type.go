@@ -2,7 +2,9 @@ package provider
 
 import (
 	"context"
+	"github.com/coreos/go-oidc/v3/oidc"
 	"golang.org/x/oauth2"
+	"net/url"
 )
 
 // OAuth2Provider specifies additional methods needed for providers using OAuth
@@ -12,6 +14,17 @@ type OAuth2Provider interface {
 	GetOAuthToken(string) (*oauth2.Token, error)
 }
 
+// IDTokenProvider is implemented by providers verifying id tokens their native SDKs issued to apps,
+// the audience has to be one of the configured client ids
+type IDTokenProvider interface {
+	ParseIDToken(context.Context, string, ParseIDTokenOptions) (*oidc.IDToken, *UserData, error)
+}
+
+// CallbackFormProvider is implemented by providers posting user data to the callback next to the code
+type CallbackFormProvider interface {
+	MergeCallbackForm(*UserData, url.Values) error
+}
+
 type UserClaims struct {
 	// Reserved claims
 	Issuer  string  `json:"iss,omitempty" structs:"iss,omitempty"`
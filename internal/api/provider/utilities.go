@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"golang.org/x/oauth2"
 	"io"
 	"net/http"
+	"strconv"
 	"surge/internal/utilities"
 	"time"
 )
@@ -27,9 +29,23 @@ func chooseHost(base, defaultHost string) string {
 }
 
 func makeRequest(ctx context.Context, tok *oauth2.Token, g *oauth2.Config, url string, dst interface{}) error {
+	return makeRequestWithHeader(ctx, tok, g, url, nil, dst)
+}
+
+// makeRequestWithHeader is makeRequest for APIs requiring headers besides the authorization, such as a client id
+func makeRequestWithHeader(ctx context.Context, tok *oauth2.Token, g *oauth2.Config, url string, header http.Header, dst interface{}) error {
 	client := g.Client(ctx, tok)
 	client.Timeout = defaultTimeout
-	res, err := client.Get(url)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	res, err := client.Do(req)
 	if err != nil {
 		return err
 	}
@@ -48,3 +64,27 @@ func makeRequest(ctx context.Context, tok *oauth2.Token, g *oauth2.Config, url s
 
 	return nil
 }
+
+// flexibleBool is a boolean claim some providers send either as a boolean or a string
+type flexibleBool bool
+
+func (b *flexibleBool) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	switch v := value.(type) {
+	case bool:
+		*b = flexibleBool(v)
+	case string:
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			return err
+		}
+		*b = flexibleBool(parsed)
+	default:
+		return fmt.Errorf("provider: unexpected boolean %s", data)
+	}
+	return nil
+}
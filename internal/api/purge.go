@@ -0,0 +1,35 @@
+package api
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"surge/internal/auth"
+	"time"
+)
+
+// purgeInterval is how often soft deleted users are looked for to be purged
+const purgeInterval = time.Hour
+
+// purgeDeletedUsers periodically deletes users soft deleted longer ago than configured, until ctx is done
+func (a *SurgeAPI) purgeDeletedUsers(ctx context.Context) {
+	logger := logrus.WithField("component", "purge")
+
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+
+	for {
+		deletedBefore := time.Now().AddDate(0, 0, -a.config.Auth.PurgeDeletedUsersAfter)
+		count, err := auth.PurgeDeletedUsers(a.queries, ctx, deletedBefore)
+		if err != nil {
+			logger.WithError(err).Error("failed to purge deleted users")
+		} else if count > 0 {
+			logger.WithField("count", count).Info("purged deleted users")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -1,6 +1,10 @@
 package api
 
-import "time"
+import (
+	"encoding/json"
+	"github.com/google/uuid"
+	"time"
+)
 
 type SignUpWithCredentialsRequest struct {
 	Username *string `json:"username"`
@@ -15,3 +19,97 @@ type SignUpWithCredentialsRequest struct {
 		Birthdate *time.Time `json:"birthdate"`
 	}
 }
+
+type UpdateUserRequest struct {
+	Username *string `json:"username"`
+	Email    *string `json:"email"`
+	Phone    *string `json:"phone"`
+
+	Metadata struct {
+		Avatar    *string                `json:"avatar"`
+		FirstName *string                `json:"first_name"`
+		LastName  *string                `json:"last_name"`
+		Birthdate *time.Time             `json:"birthdate"`
+		Extra     map[string]interface{} `json:"extra"`
+	} `json:"metadata"`
+}
+
+// AdminCreateUserRequest creates a user as an administrator, confirmations are not sent to the user
+type AdminCreateUserRequest struct {
+	SignUpWithCredentialsRequest
+
+	EmailConfirm bool `json:"email_confirm"`
+	PhoneConfirm bool `json:"phone_confirm"`
+}
+
+// AdminUpdateUserRequest updates a user as an administrator, fields absent from the body are left unchanged
+type AdminUpdateUserRequest struct {
+	UpdateUserRequest
+
+	Password     *string `json:"password"`
+	EmailConfirm *bool   `json:"email_confirm"`
+	PhoneConfirm *bool   `json:"phone_confirm"`
+}
+
+// AdminBanUserRequest bans a user for Duration, a Go duration such as "24h", or lifts the ban with "none"
+type AdminBanUserRequest struct {
+	Duration string `json:"duration"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+
+	// RevokeOtherSessions signs out every other session of the user after changing the password
+	RevokeOtherSessions bool `json:"revoke_other_sessions"`
+}
+
+type RecoverRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+type OTPType = string
+
+const (
+	OTPTypeMagicLink OTPType = "magiclink"
+	OTPTypeCode      OTPType = "code"
+)
+
+// OTPRequest requests a one-time password to either email or phone, phone only supports OTPTypeCode
+type OTPRequest struct {
+	Email *string `json:"email"`
+	Phone *string `json:"phone"`
+	Type  OTPType `json:"type"`
+	// CreateUser can opt out of creating a user for unknown email or phone, only effective if enabled in configuration
+	CreateUser *bool `json:"create_user"`
+}
+
+type EnrollFactorRequest struct {
+	FactorType   string  `json:"factor_type"`
+	FriendlyName *string `json:"friendly_name"`
+}
+
+// ChallengeFactorRequest carries the mfa token while signing in, signed in users use their access token instead
+type ChallengeFactorRequest struct {
+	MFAToken *string `json:"mfa_token"`
+}
+
+// VerifyFactorRequest carries the mfa token while signing in, signed in users use their access token instead.
+// Code is for totp factors, Credential is the PublicKeyCredential created or asserted for webauthn factors.
+type VerifyFactorRequest struct {
+	ChallengeID uuid.UUID       `json:"challenge_id"`
+	Code        string          `json:"code"`
+	Credential  json.RawMessage `json:"credential"`
+	MFAToken    *string         `json:"mfa_token"`
+}
+
+// VerifyRecoveryCodeRequest carries the mfa token while signing in, signed in users use their access token instead
+type VerifyRecoveryCodeRequest struct {
+	Code     string  `json:"code"`
+	MFAToken *string `json:"mfa_token"`
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"encoding/json"
 	"github.com/google/uuid"
 	"github.com/lestrrat-go/jwx/v2/jwk"
 	"net/url"
@@ -19,6 +20,11 @@ type AccessTokenResponse struct {
 	User         *UserResponse `json:"user"`
 }
 
+// SignInResponse is what signing in with a first factor responds with, either AccessTokenResponse or MFARequiredResponse
+type SignInResponse interface {
+	MakeRedirectUrl(redirectURL string, extraParams url.Values) string
+}
+
 // MakeRedirectUrl makes the token response to url so client can read token from query parameters
 func (r *AccessTokenResponse) MakeRedirectUrl(redirectURL string, extraParams url.Values) string {
 	extraParams.Set("access_token", r.AccessToken)
@@ -35,24 +41,198 @@ type UserResponse struct {
 
 	Email    *string `json:"email"`
 	Username *string `json:"username"`
+	Phone    *string `json:"phone"`
+	// NewEmail is the email the user asked to change to, until it is confirmed
+	NewEmail *string `json:"new_email,omitempty"`
+
+	Metadata UserMetadataResponse `json:"metadata"`
+
+	EmailConfirmedAt *time.Time `json:"email_confirmed_at"`
+	PhoneConfirmedAt *time.Time `json:"phone_confirmed_at"`
 
 	CreatedAt  time.Time  `json:"created_at"`
 	UpdatedAt  time.Time  `json:"updated_at"`
 	LastSignIn *time.Time `json:"last_sign_in"`
 }
 
+type UserMetadataResponse struct {
+	Avatar    *string         `json:"avatar"`
+	FirstName *string         `json:"first_name"`
+	LastName  *string         `json:"last_name"`
+	Birthdate *time.Time      `json:"birthdate"`
+	Extra     json.RawMessage `json:"extra"`
+}
+
 func NewUserResponse(user *schema.AuthUser) *UserResponse {
 	return &UserResponse{
-		ID:         user.ID,
-		Email:      storage.NullStringToPointer(user.Email),
-		Username:   storage.NullStringToPointer(user.Username),
-		CreatedAt:  user.CreatedAt,
-		UpdatedAt:  user.UpdatedAt,
-		LastSignIn: storage.NullTimeToPointer(user.LastSignIn),
+		ID:       user.ID,
+		Email:    storage.NullStringToPointer(user.Email),
+		Username: storage.NullStringToPointer(user.Username),
+		Phone:    storage.InterfaceToStringPointer(user.Phone),
+		NewEmail: storage.NullStringToPointer(user.EmailChange),
+		Metadata: UserMetadataResponse{
+			Avatar:    storage.NullStringToPointer(user.MetaAvatar),
+			FirstName: storage.NullStringToPointer(user.MetaFirstName),
+			LastName:  storage.NullStringToPointer(user.MetaLastName),
+			Birthdate: storage.NullTimeToPointer(user.MetaBirthdate),
+			Extra:     user.MetaExtra,
+		},
+		EmailConfirmedAt: storage.NullTimeToPointer(user.EmailConfirmedAt),
+		PhoneConfirmedAt: storage.NullTimeToPointer(user.PhoneConfirmedAt),
+		CreatedAt:        user.CreatedAt,
+		UpdatedAt:        user.UpdatedAt,
+		LastSignIn:       storage.NullTimeToPointer(user.LastSignIn),
+	}
+}
+
+// AdminUserResponse is UserResponse with fields only administrators can see
+type AdminUserResponse struct {
+	*UserResponse
+	BannedUntil *time.Time `json:"banned_until"`
+	DeletedAt   *time.Time `json:"deleted_at"`
+}
+
+func NewAdminUserResponse(user *schema.AuthUser) *AdminUserResponse {
+	return &AdminUserResponse{
+		UserResponse: NewUserResponse(user),
+		BannedUntil:  storage.NullTimeToPointer(user.BannedUntil),
+		DeletedAt:    storage.NullTimeToPointer(user.DeletedAt),
+	}
+}
+
+// AdminUsersResponse is a page of users, Total counts users across every page
+type AdminUsersResponse struct {
+	Users   []*AdminUserResponse `json:"users"`
+	Total   int64                `json:"total"`
+	Page    int                  `json:"page"`
+	PerPage int                  `json:"per_page"`
+}
+
+// SessionResponse represents a session of the user
+type SessionResponse struct {
+	ID uuid.UUID `json:"id"`
+
+	UserAgent *string `json:"user_agent"`
+	IpAddress *string `json:"ip_address"`
+	Current   bool    `json:"current"`
+
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	RefreshedAt *time.Time `json:"refreshed_at"`
+}
+
+func NewSessionResponse(session *schema.AuthSession, currentSessionID string) *SessionResponse {
+	return &SessionResponse{
+		ID:          session.ID,
+		UserAgent:   storage.NullStringToPointer(session.UserAgent),
+		IpAddress:   storage.NullStringToPointer(session.IpAddress),
+		Current:     session.ID.String() == currentSessionID,
+		CreatedAt:   session.CreatedAt,
+		UpdatedAt:   session.UpdatedAt,
+		RefreshedAt: storage.NullTimeToPointer(session.RefreshedAt),
+	}
+}
+
+// IdentityResponse represents an external identity linked to the user
+type IdentityResponse struct {
+	ID         uuid.UUID `json:"id"`
+	Provider   string    `json:"provider"`
+	ProviderID string    `json:"provider_id"`
+
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	LastSignIn *time.Time `json:"last_sign_in"`
+}
+
+func NewIdentityResponse(identity *schema.AuthIdentity) *IdentityResponse {
+	return &IdentityResponse{
+		ID:         identity.ID,
+		Provider:   identity.Provider,
+		ProviderID: identity.ProviderID,
+		CreatedAt:  identity.CreatedAt,
+		UpdatedAt:  identity.UpdatedAt,
+		LastSignIn: storage.NullTimeToPointer(identity.LastSignIn),
 	}
 }
 
+// LinkIdentityResponse is the url of the provider to visit for linking an identity to the user
+type LinkIdentityResponse struct {
+	URL string `json:"url"`
+}
+
 // JwksResponse is response type for /.well-known/jwks.json endpoint
 type JwksResponse struct {
 	Keys []jwk.Key `json:"keys"`
 }
+
+type FactorResponse struct {
+	ID           uuid.UUID `json:"id"`
+	FactorType   string    `json:"factor_type"`
+	Status       string    `json:"status"`
+	FriendlyName *string   `json:"friendly_name"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func NewFactorResponse(factor *schema.AuthMfaFactor) *FactorResponse {
+	return &FactorResponse{
+		ID:           factor.ID,
+		FactorType:   factor.FactorType,
+		Status:       factor.Status,
+		FriendlyName: storage.NullStringToPointer(factor.FriendlyName),
+		CreatedAt:    factor.CreatedAt,
+		UpdatedAt:    factor.UpdatedAt,
+	}
+}
+
+type EnrollFactorResponse struct {
+	*FactorResponse
+	TOTP *TOTPEnrollmentResponse `json:"totp,omitempty"`
+}
+
+// TOTPEnrollmentResponse is what authenticator apps need to register the factor, QRCode is a png data uri of URI
+type TOTPEnrollmentResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+	QRCode string `json:"qr_code"`
+}
+
+// MFARequiredResponse is responded instead of tokens when signing in requires a second factor
+type MFARequiredResponse struct {
+	MFARequired bool              `json:"mfa_required"`
+	MFAToken    string            `json:"mfa_token"`
+	ExpiresAt   int64             `json:"expires_at"`
+	Factors     []*FactorResponse `json:"factors"`
+}
+
+// MakeRedirectUrl makes the mfa token to url so client can complete signing in with a factor of the user
+func (r *MFARequiredResponse) MakeRedirectUrl(redirectURL string, extraParams url.Values) string {
+	extraParams.Set("mfa_required", "true")
+	extraParams.Set("mfa_token", r.MFAToken)
+	extraParams.Set("expires_at", strconv.FormatInt(r.ExpiresAt, 10))
+
+	return redirectURL + "#" + extraParams.Encode()
+}
+
+// RecoveryCodesResponse carries the generated recovery codes, which are not shown again
+type RecoveryCodesResponse struct {
+	Codes []string `json:"codes"`
+}
+
+type RecoveryCodesStatusResponse struct {
+	Remaining int64 `json:"remaining"`
+}
+
+// ChallengeResponse carries the options to pass to the WebAuthn API of the browser for webauthn factors
+type ChallengeResponse struct {
+	ID        uuid.UUID   `json:"id"`
+	ExpiresAt int64       `json:"expires_at"`
+	WebAuthn  interface{} `json:"webauthn,omitempty"`
+}
+
+// WebAuthnChallengeResponse carries the options to pass to navigator.credentials.get() to sign in with a passkey
+type WebAuthnChallengeResponse struct {
+	ID        uuid.UUID   `json:"id"`
+	ExpiresAt int64       `json:"expires_at"`
+	WebAuthn  interface{} `json:"webauthn"`
+}
@@ -31,6 +31,9 @@ func (r *SurgeAPIRouter) Post(pattern string, fn surgeAPIHandler) {
 func (r *SurgeAPIRouter) Put(pattern string, fn surgeAPIHandler) {
 	r.chi.Put(pattern, handler(fn))
 }
+func (r *SurgeAPIRouter) Patch(pattern string, fn surgeAPIHandler) {
+	r.chi.Patch(pattern, handler(fn))
+}
 func (r *SurgeAPIRouter) Delete(pattern string, fn surgeAPIHandler) {
 	r.chi.Delete(pattern, handler(fn))
 }
@@ -42,6 +42,14 @@ func (a *SurgeAPI) createRouter() *SurgeAPIRouter {
 
 		router.Post("/token", a.EndpointToken)
 
+		router.Get("/verify", a.EndpointVerify)
+		router.Post("/verify", a.EndpointVerifyPost)
+		router.Post("/resend", a.EndpointResend)
+		router.Post("/recover", a.EndpointRecover)
+		router.Post("/reset_password", a.EndpointResetPassword)
+		router.Post("/otp", a.EndpointOTP)
+		router.Post("/webauthn/challenge", a.EndpointWebAuthnChallenge)
+
 		router.Route("/external", func(router *SurgeAPIRouter) {
 			router.Get("/", a.EndpointExternal)
 
@@ -53,11 +61,61 @@ func (a *SurgeAPI) createRouter() *SurgeAPIRouter {
 			})
 		})
 
+		// Factors are challenged with an access token, or with an mfa token while signing in
+		router.Route("/factors", func(router *SurgeAPIRouter) {
+			router.Use(a.useOptionalAuthentication)
+
+			router.Post("/recovery_code/verify", a.EndpointVerifyRecoveryCode)
+			router.Post("/{factor_id}/challenge", a.EndpointChallengeFactor)
+			router.Post("/{factor_id}/verify", a.EndpointVerifyFactor)
+		})
+
 		router.Route("/user", func(router *SurgeAPIRouter) {
 			router.Use(a.useAuthentication)
 
 			router.Get("/", a.EndpointUser)
-			// TODO: Add update user route (POST|PUT /user)
+			router.Put("/", a.EndpointUpdateUser)
+			router.Patch("/", a.EndpointUpdateUser)
+			router.Delete("/", a.EndpointDeleteUser)
+			router.Put("/password", a.EndpointChangePassword)
+
+			router.Route("/sessions", func(router *SurgeAPIRouter) {
+				router.Get("/", a.EndpointSessions)
+				router.Delete("/{session_id}", a.EndpointDeleteSession)
+			})
+
+			router.Route("/identities", func(router *SurgeAPIRouter) {
+				router.Get("/", a.EndpointIdentities)
+				router.Get("/link", a.EndpointLinkIdentity)
+				router.Delete("/{identity_id}", a.EndpointDeleteIdentity)
+			})
+
+			router.Route("/factors", func(router *SurgeAPIRouter) {
+				router.Get("/", a.EndpointFactors)
+				router.Post("/", a.EndpointEnrollFactor)
+				router.Delete("/{factor_id}", a.EndpointDeleteFactor)
+				router.Get("/recovery_codes", a.EndpointRecoveryCodes)
+				router.Post("/recovery_codes", a.EndpointRegenerateRecoveryCodes)
+			})
+		})
+
+		// Administration with the service role key or a JWT with an admin role claim
+		router.Route("/admin", func(router *SurgeAPIRouter) {
+			router.Use(a.useAdminAuthentication)
+
+			router.Route("/users", func(router *SurgeAPIRouter) {
+				router.Get("/", a.EndpointAdminUsers)
+				router.Post("/", a.EndpointAdminCreateUser)
+
+				router.Route("/{user_id}", func(router *SurgeAPIRouter) {
+					router.Get("/", a.EndpointAdminUser)
+					router.Put("/", a.EndpointAdminUpdateUser)
+					router.Patch("/", a.EndpointAdminUpdateUser)
+					router.Delete("/", a.EndpointAdminDeleteUser)
+					router.Post("/ban", a.EndpointAdminBanUser)
+					router.Post("/logout", a.EndpointAdminSignOutUser)
+				})
+			})
 		})
 	})
 
@@ -0,0 +1,149 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"net/http"
+	"surge/internal/auth"
+	"surge/internal/schema"
+	"surge/internal/utilities"
+	"time"
+)
+
+// AuthenticatorAssuranceLevel is how confident it is that the user of a session is who they claim to be
+type AuthenticatorAssuranceLevel = string
+
+const (
+	// AAL1 sessions are authenticated with a single factor
+	AAL1 AuthenticatorAssuranceLevel = "aal1"
+	// AAL2 sessions are authenticated with a second factor as well
+	AAL2 AuthenticatorAssuranceLevel = "aal2"
+)
+
+type AuthenticationMethod = string
+
+const (
+	AuthenticationMethodPassword     AuthenticationMethod = "password"
+	AuthenticationMethodOTP          AuthenticationMethod = "otp"
+	AuthenticationMethodMagicLink    AuthenticationMethod = "magiclink"
+	AuthenticationMethodRecovery     AuthenticationMethod = "recovery"
+	AuthenticationMethodOAuth        AuthenticationMethod = "oauth"
+	AuthenticationMethodIDToken      AuthenticationMethod = "id_token"
+	AuthenticationMethodTOTP         AuthenticationMethod = "totp"
+	AuthenticationMethodWebAuthn     AuthenticationMethod = "webauthn"
+	AuthenticationMethodRecoveryCode AuthenticationMethod = "recovery_code"
+	AuthenticationMethodTokenRefresh AuthenticationMethod = "token_refresh"
+)
+
+// AMREntry is an authentication method used within a session, as in the amr claim
+type AMREntry struct {
+	Method    AuthenticationMethod `json:"method"`
+	Timestamp int64                `json:"timestamp"`
+}
+
+// createSession creates a new AAL1 session of the user authenticated by method, recording the client information from the request
+func (a *SurgeAPI) createSession(r *http.Request, queries *schema.Queries, user *schema.AuthUser, method AuthenticationMethod) (*schema.AuthSession, error) {
+	if auth.IsUserBanned(user) {
+		return nil, userBannedError(user)
+	}
+	if user.DeletedAt.Valid {
+		return nil, ForbiddenError(ErrorCodeUserNotFound, "user is deleted")
+	}
+
+	userAgent := r.UserAgent()
+	ipAddress := utilities.GetIPAddress(r)
+
+	amr, err := json.Marshal([]AMREntry{{Method: method, Timestamp: time.Now().Unix()}})
+	if err != nil {
+		return nil, err
+	}
+
+	return queries.CreateSession(r.Context(), schema.CreateSessionParams{
+		UserID:    user.ID,
+		UserAgent: sql.NullString{String: userAgent, Valid: userAgent != ""},
+		IpAddress: sql.NullString{String: ipAddress, Valid: ipAddress != ""},
+		Aal:       AAL1,
+		Amr:       amr,
+	})
+}
+
+// addSessionAuthentication records that the session was authenticated by method, raising it to aal
+func (a *SurgeAPI) addSessionAuthentication(ctx context.Context, queries *schema.Queries, session *schema.AuthSession, method AuthenticationMethod, aal AuthenticatorAssuranceLevel) (*schema.AuthSession, error) {
+	amr := getSessionAMR(session)
+	amr = append(amr, AMREntry{Method: method, Timestamp: time.Now().Unix()})
+
+	marshalled, err := json.Marshal(amr)
+	if err != nil {
+		return nil, err
+	}
+
+	return queries.UpdateSessionAuthentication(ctx, schema.UpdateSessionAuthenticationParams{
+		ID:  session.ID,
+		Aal: aal,
+		Amr: marshalled,
+	})
+}
+
+// getSessionAMR reads the authentication methods used within the session
+func getSessionAMR(session *schema.AuthSession) []AMREntry {
+	var amr []AMREntry
+	if err := json.Unmarshal(session.Amr, &amr); err != nil {
+		return []AMREntry{}
+	}
+	return amr
+}
+
+// getSubjectUser reads the user an access token was issued to, soft deleted users are reported as missing
+// since their access tokens stay valid until they expire
+func getSubjectUser(ctx context.Context, queries *schema.Queries, userId uuid.UUID) (*schema.AuthUser, error) {
+	user, err := queries.GetUser(ctx, userId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ForbiddenError(ErrorCodeUserNotFound, "token subject user does not exist")
+		}
+		return nil, err
+	}
+	if user.DeletedAt.Valid {
+		return nil, ForbiddenError(ErrorCodeUserNotFound, "token subject user does not exist")
+	}
+	return user, nil
+}
+
+// requireAAL2IfEnrolled requires the session of claims to be verified with a second factor if the user has a verified factor
+func requireAAL2IfEnrolled(ctx context.Context, queries *schema.Queries, user *schema.AuthUser, claims *AccessTokenClaims) error {
+	factors, err := queries.ListVerifiedFactorsOfUser(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	if len(factors) > 0 && claims.AAL != AAL2 {
+		return ForbiddenError(ErrorCodeInsufficientAssurance, "session has to be verified with a second factor")
+	}
+	return nil
+}
+
+// userBannedError is responded to banned users instead of signing them in
+func userBannedError(user *schema.AuthUser) *HTTPError {
+	return ForbiddenError(ErrorCodeUserBanned, "user is banned until %s", user.BannedUntil.Time.Format(time.RFC3339))
+}
+
+// revokeAllSessions revokes every session of the user, including refresh tokens without a session
+func (a *SurgeAPI) revokeAllSessions(ctx context.Context, queries *schema.Queries, userId uuid.UUID) error {
+	if err := queries.RevokeRefreshTokensOfUser(ctx, uuid.NullUUID{UUID: userId, Valid: true}); err != nil {
+		return err
+	}
+	return queries.DeleteSessionsOfUser(ctx, userId)
+}
+
+// revokeOtherSessions revokes every session of the user except the current one, including refresh tokens without a session
+func (a *SurgeAPI) revokeOtherSessions(ctx context.Context, queries *schema.Queries, userId uuid.UUID, currentSessionId uuid.UUID) error {
+	if err := queries.RevokeSessionlessRefreshTokensOfUser(ctx, uuid.NullUUID{UUID: userId, Valid: true}); err != nil {
+		return err
+	}
+	return queries.DeleteOtherSessionsOfUser(ctx, schema.DeleteOtherSessionsOfUserParams{
+		UserID:           userId,
+		CurrentSessionID: currentSessionId,
+	})
+}
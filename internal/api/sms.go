@@ -0,0 +1,46 @@
+package api
+
+import (
+	"net/http"
+	"surge/internal/auth"
+	"surge/internal/schema"
+	"surge/internal/sms"
+	"surge/internal/storage"
+	"time"
+)
+
+// confirmOrSendPhoneConfirmation confirms phone of the user right away if configured, otherwise sends a code to it.
+// The code is consumed by the otp grant type, which confirms the phone as well.
+func (a *SurgeAPI) confirmOrSendPhoneConfirmation(r *http.Request, queries *schema.Queries, user *schema.AuthUser) (*schema.AuthUser, error) {
+	if storage.InterfaceToStringPointer(user.Phone) == nil {
+		return user, nil
+	}
+
+	if a.config.Auth.AutoConfirmPhone {
+		return auth.SetPhoneConfirmed(queries, r.Context(), user, true)
+	}
+
+	return user, a.sendPhoneOTP(r, queries, user)
+}
+
+// sendPhoneOTP sends a code which signs the user in through the otp grant type
+func (a *SurgeAPI) sendPhoneOTP(r *http.Request, queries *schema.Queries, user *schema.AuthUser) error {
+	phone := *storage.InterfaceToStringPointer(user.Phone)
+
+	code, err := auth.CreateOneTimeToken(queries, r.Context(), a.config, user, auth.CreateOneTimeTokenOptions{
+		Type:           auth.OneTimeTokenPhoneOTP,
+		RelatesTo:      phone,
+		ExpiresAfter:   time.Second * time.Duration(a.config.SMS.OTPExpiresAfter),
+		Numeric:        true,
+		ResendInterval: time.Second * time.Duration(a.config.SMS.OTPResendInterval),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := a.smsSender.Send(r.Context(), sms.NewOTPMessage(phone, code)); err != nil {
+		return InternalServerError("failed to send one-time password sms: %+v", err)
+	}
+
+	return nil
+}
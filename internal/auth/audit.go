@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"surge/internal/schema"
+)
+
+type AuditAction = string
+
+const (
+	AuditActionRecoveryCodesGenerated AuditAction = "mfa_recovery_codes_generated"
+	AuditActionRecoveryCodeUsed       AuditAction = "mfa_recovery_code_used"
+	AuditActionUserDeleted            AuditAction = "user_deleted"
+)
+
+// RecordAuditEvent writes an entry to the audit log, payload holds details of the action
+func RecordAuditEvent(queries *schema.Queries, ctx context.Context, userId uuid.UUID, action AuditAction, ipAddress string, payload map[string]interface{}) error {
+	if payload == nil {
+		payload = map[string]interface{}{}
+	}
+
+	marshalled, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	if err := queries.CreateAuditLogEntry(ctx, schema.CreateAuditLogEntryParams{
+		UserID:    uuid.NullUUID{UUID: userId, Valid: true},
+		Action:    action,
+		IpAddress: sql.NullString{String: ipAddress, Valid: ipAddress != ""},
+		Payload:   marshalled,
+	}); err != nil {
+		logrus.WithError(err).Error(ErrDatabaseJob)
+		return ErrDatabaseJob
+	}
+
+	return nil
+}
@@ -13,7 +13,21 @@ var (
 
 	ErrRequiredEmail    = errors.New("email field required")
 	ErrRequiredUsername = errors.New("username field required")
-	ErrRequiredPhone    = errors.New("username field required")
+	ErrRequiredPhone    = errors.New("phone field required")
+
+	ErrWrongPassword = errors.New("wrong password")
+	ErrNoPassword    = errors.New("user has no password")
+
+	ErrTokenNotFound = errors.New("token not found")
+	ErrTokenExpired  = errors.New("token expired")
+	ErrTokenTooSoon  = errors.New("token requested too soon")
+
+	ErrChallengeNotFound = errors.New("challenge not found")
+	ErrChallengeExpired  = errors.New("challenge expired")
+	ErrWrongCode         = errors.New("wrong code")
+	ErrFactorLocked      = errors.New("factor locked")
+
+	ErrWebAuthnFailed = errors.New("webauthn ceremony failed")
 
 	ErrMissingField = errors.New("missing field")
 	ErrDatabaseJob  = errors.New("database job failed")
@@ -0,0 +1,233 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+	"github.com/sirupsen/logrus"
+	"github.com/sqlc-dev/pqtype"
+	"surge/internal/conf"
+	"surge/internal/schema"
+	"surge/internal/storage"
+	"time"
+)
+
+type FactorType = string
+
+const (
+	FactorTypeTOTP     FactorType = "totp"
+	FactorTypeWebAuthn FactorType = "webauthn"
+)
+
+type FactorStatus = string
+
+const (
+	// FactorStatusUnverified factors are enrolled but were never verified, they can't be used to sign in
+	FactorStatusUnverified FactorStatus = "unverified"
+	FactorStatusVerified   FactorStatus = "verified"
+)
+
+type EnrollTOTPFactorOptions struct {
+	FriendlyName *string
+	// Issuer and AccountName are shown in authenticator apps
+	Issuer      string
+	AccountName string
+}
+
+// EnrollTOTPFactor creates an unverified TOTP factor of the user, returning the key to be registered in an authenticator app
+func EnrollTOTPFactor(queries *schema.Queries, ctx context.Context, user *schema.AuthUser, options EnrollTOTPFactorOptions) (*schema.AuthMfaFactor, *otp.Key, error) {
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      options.Issuer,
+		AccountName: options.AccountName,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	factor, err := queries.CreateFactor(ctx, schema.CreateFactorParams{
+		UserID:       user.ID,
+		FactorType:   FactorTypeTOTP,
+		Status:       FactorStatusUnverified,
+		FriendlyName: storage.NewNullableString(options.FriendlyName),
+		Secret:       storage.NewString(key.Secret()),
+	})
+	if err != nil {
+		logrus.WithError(err).Error(ErrDatabaseJob)
+		return nil, nil, ErrDatabaseJob
+	}
+
+	return factor, key, nil
+}
+
+// CreateChallenge creates a challenge for the factor, which has to be verified before it expires.
+// sessionData is the ceremony state of webauthn factors.
+func CreateChallenge(queries *schema.Queries, ctx context.Context, factor *schema.AuthMfaFactor, ipAddress string, sessionData *webauthn.SessionData) (*schema.AuthMfaChallenge, error) {
+	webAuthnSessionData := pqtype.NullRawMessage{}
+	if sessionData != nil {
+		marshalled, err := json.Marshal(sessionData)
+		if err != nil {
+			return nil, err
+		}
+		webAuthnSessionData = pqtype.NullRawMessage{RawMessage: marshalled, Valid: true}
+	}
+
+	challenge, err := queries.CreateChallenge(ctx, schema.CreateChallengeParams{
+		FactorID:            factor.ID,
+		IpAddress:           sql.NullString{String: ipAddress, Valid: ipAddress != ""},
+		WebauthnSessionData: webAuthnSessionData,
+	})
+	if err != nil {
+		logrus.WithError(err).Error(ErrDatabaseJob)
+		return nil, ErrDatabaseJob
+	}
+
+	return challenge, nil
+}
+
+// VerifyChallenge validates the TOTP code of the factor for the challenge, so the challenge can't be verified again.
+// Unverified factors become verified once a challenge of them is verified.
+// Wrong codes are counted even though ErrWrongCode is returned, so the caller has to commit them.
+func VerifyChallenge(queries *schema.Queries, ctx context.Context, config *conf.SurgeConfigurations, factor *schema.AuthMfaFactor, challengeId uuid.UUID, code string) (*schema.AuthMfaFactor, error) {
+	// Locking the factor serializes verifying concurrent challenges of it
+	factor, err := queries.GetFactorForUpdate(ctx, factor.ID)
+	if err != nil {
+		logrus.WithError(err).Error(ErrDatabaseJob)
+		return nil, ErrDatabaseJob
+	}
+
+	if factor.LockedUntil.Valid && time.Now().Before(factor.LockedUntil.Time) {
+		return nil, ErrFactorLocked
+	}
+
+	challenge, err := getPendingChallenge(queries, ctx, config, factor, challengeId)
+	if err != nil {
+		return nil, err
+	}
+
+	step, ok := validateTOTPCode(code, factor.Secret.String, factor.LastTotpStep)
+	if !ok {
+		if err := recordFailedChallenge(queries, ctx, config, factor, challenge); err != nil {
+			return nil, err
+		}
+		return nil, ErrWrongCode
+	}
+
+	factor, err = queries.UpdateFactorLastTotpStep(ctx, schema.UpdateFactorLastTotpStepParams{
+		ID:           factor.ID,
+		LastTotpStep: sql.NullInt64{Int64: step, Valid: true},
+	})
+	if err != nil {
+		logrus.WithError(err).Error(ErrDatabaseJob)
+		return nil, ErrDatabaseJob
+	}
+
+	return completeChallenge(queries, ctx, factor, challenge)
+}
+
+// validateTOTPCode checks the code against the time steps around now, allowing one step of clock skew.
+// Codes of the last accepted step or earlier are rejected so an observed code can't be replayed.
+func validateTOTPCode(code string, secret string, lastStep sql.NullInt64) (int64, bool) {
+	const period = 30
+
+	now := time.Now().Unix() / period
+	for step := now - 1; step <= now+1; step++ {
+		if lastStep.Valid && step <= lastStep.Int64 {
+			continue
+		}
+
+		valid, _ := totp.ValidateCustom(code, secret, time.Unix(step*period, 0).UTC(), totp.ValidateOpts{
+			Period:    period,
+			Digits:    otp.DigitsSix,
+			Algorithm: otp.AlgorithmSHA1,
+		})
+		if valid {
+			return step, true
+		}
+	}
+
+	return 0, false
+}
+
+// recordFailedChallenge counts a wrong code for the challenge and the factor.
+// The challenge is deleted after too many attempts, the factor is locked after too many failures in a row.
+func recordFailedChallenge(queries *schema.Queries, ctx context.Context, config *conf.SurgeConfigurations, factor *schema.AuthMfaFactor, challenge *schema.AuthMfaChallenge) error {
+	attempts, err := queries.IncrementChallengeFailedAttempts(ctx, challenge.ID)
+	if err != nil {
+		logrus.WithError(err).Error(ErrDatabaseJob)
+		return ErrDatabaseJob
+	}
+
+	if int(attempts) >= config.MFA.MaxChallengeAttempts {
+		if err := queries.DeleteChallenge(ctx, challenge.ID); err != nil {
+			logrus.WithError(err).Error(ErrDatabaseJob)
+			return ErrDatabaseJob
+		}
+	}
+
+	failures := factor.FailedAttempts + 1
+	lockedUntil := sql.NullTime{}
+	if int(failures) >= config.MFA.MaxFactorFailures {
+		failures = 0
+		lockedUntil = sql.NullTime{Time: time.Now().Add(time.Second * time.Duration(config.MFA.FactorLockoutDuration)), Valid: true}
+	}
+
+	_, err = queries.UpdateFactorFailedAttempts(ctx, schema.UpdateFactorFailedAttemptsParams{
+		ID:             factor.ID,
+		FailedAttempts: failures,
+		LockedUntil:    lockedUntil,
+	})
+	if err != nil {
+		logrus.WithError(err).Error(ErrDatabaseJob)
+		return ErrDatabaseJob
+	}
+
+	return nil
+}
+
+// getPendingChallenge looks up the challenge of the factor which is neither verified nor expired
+func getPendingChallenge(queries *schema.Queries, ctx context.Context, config *conf.SurgeConfigurations, factor *schema.AuthMfaFactor, challengeId uuid.UUID) (*schema.AuthMfaChallenge, error) {
+	challenge, err := queries.GetChallenge(ctx, challengeId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrChallengeNotFound
+		}
+		return nil, ErrDatabaseJob
+	}
+
+	if challenge.FactorID != factor.ID || challenge.VerifiedAt.Valid {
+		return nil, ErrChallengeNotFound
+	}
+
+	if time.Since(challenge.CreatedAt) > time.Second*time.Duration(config.MFA.ChallengeExpiresAfter) {
+		return nil, ErrChallengeExpired
+	}
+
+	return challenge, nil
+}
+
+// completeChallenge marks the challenge verified, verifying the factor as well if it wasn't
+func completeChallenge(queries *schema.Queries, ctx context.Context, factor *schema.AuthMfaFactor, challenge *schema.AuthMfaChallenge) (*schema.AuthMfaFactor, error) {
+	if err := queries.UpdateChallengeVerifiedAt(ctx, challenge.ID); err != nil {
+		return nil, ErrDatabaseJob
+	}
+
+	if factor.Status == FactorStatusVerified {
+		return factor, nil
+	}
+
+	factor, err := queries.UpdateFactorStatus(ctx, schema.UpdateFactorStatusParams{
+		ID:     factor.ID,
+		Status: FactorStatusVerified,
+	})
+	if err != nil {
+		logrus.WithError(err).Error(ErrDatabaseJob)
+		return nil, ErrDatabaseJob
+	}
+
+	return factor, nil
+}
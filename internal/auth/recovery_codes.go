@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"github.com/sirupsen/logrus"
+	"io"
+	"strings"
+	"surge/internal/conf"
+	"surge/internal/schema"
+	"surge/internal/utilities"
+)
+
+// recoveryCodeEncoding avoids padding and ambiguous characters of base64, recovery codes are typed by hand
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateRecoveryCodes replaces the recovery codes of the user with new ones, returning them once in plaintext
+func GenerateRecoveryCodes(queries *schema.Queries, ctx context.Context, config *conf.SurgeConfigurations, user *schema.AuthUser) ([]string, error) {
+	if err := queries.DeleteRecoveryCodesOfUser(ctx, user.ID); err != nil {
+		logrus.WithError(err).Error(ErrDatabaseJob)
+		return nil, ErrDatabaseJob
+	}
+
+	codes := make([]string, config.MFA.RecoveryCodeCount)
+	for i := range codes {
+		codes[i] = newRecoveryCode()
+
+		if err := queries.CreateRecoveryCode(ctx, schema.CreateRecoveryCodeParams{
+			UserID:   user.ID,
+			CodeHash: hashRecoveryCode(config, codes[i]),
+		}); err != nil {
+			logrus.WithError(err).Error(ErrDatabaseJob)
+			return nil, ErrDatabaseJob
+		}
+	}
+
+	return codes, nil
+}
+
+// ConsumeRecoveryCode marks the unused recovery code of the user used, so it can't be used again
+func ConsumeRecoveryCode(queries *schema.Queries, ctx context.Context, config *conf.SurgeConfigurations, user *schema.AuthUser, code string) (*schema.AuthMfaRecoveryCode, error) {
+	recoveryCode, err := queries.GetUnusedRecoveryCode(ctx, schema.GetUnusedRecoveryCodeParams{
+		UserID:   user.ID,
+		CodeHash: hashRecoveryCode(config, code),
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrWrongCode
+		}
+		return nil, ErrDatabaseJob
+	}
+
+	if err := queries.UpdateRecoveryCodeUsedAt(ctx, recoveryCode.ID); err != nil {
+		logrus.WithError(err).Error(ErrDatabaseJob)
+		return nil, ErrDatabaseJob
+	}
+
+	return recoveryCode, nil
+}
+
+// newRecoveryCode creates a random code of 10 characters, formatted as xxxxx-xxxxx
+func newRecoveryCode() string {
+	b := make([]byte, 10)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		panic(err.Error()) // rand should never fail
+	}
+	code := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))[:10]
+	return code[:5] + "-" + code[5:]
+}
+
+// hashRecoveryCode hashes the code regardless of case and separators, as users may type them either way
+func hashRecoveryCode(config *conf.SurgeConfigurations, code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	return utilities.HashToken(normalized, []byte(config.JWT.Secret))
+}
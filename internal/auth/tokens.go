@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"database/sql"
+	"errors"
+	"github.com/sirupsen/logrus"
+	"surge/internal/conf"
+	"surge/internal/schema"
+	"surge/internal/utilities"
+	"time"
+)
+
+type OneTimeTokenType = string
+
+const (
+	OneTimeTokenConfirmation OneTimeTokenType = "confirmation"
+	OneTimeTokenEmailChange  OneTimeTokenType = "email_change"
+	OneTimeTokenRecovery     OneTimeTokenType = "recovery"
+	OneTimeTokenMagicLink    OneTimeTokenType = "magiclink"
+	OneTimeTokenEmailOTP     OneTimeTokenType = "email_otp"
+	OneTimeTokenPhoneOTP     OneTimeTokenType = "phone_otp"
+	// OneTimeTokenMFA is handed out after the first factor to complete sign in with a second factor
+	OneTimeTokenMFA OneTimeTokenType = "mfa"
+)
+
+// oneTimeTokenLength is the amount of random bytes a one-time token is made of
+const oneTimeTokenLength = 32
+
+// oneTimeCodeDigits is the amount of digits a one-time code typed by the user is made of
+const oneTimeCodeDigits = 6
+
+// maxOneTimeCodeAttempts is how many wrong codes delete a one-time code, so it can't be guessed
+const maxOneTimeCodeAttempts = 5
+
+type CreateOneTimeTokenOptions struct {
+	Type OneTimeTokenType
+	// RelatesTo is the email or phone the token is delivered to, or the authentication method of OneTimeTokenMFA
+	RelatesTo    string
+	ExpiresAfter time.Duration
+	// Numeric creates a short numeric code instead, which can only be consumed with ConsumeOneTimeTokenOfUser
+	Numeric bool
+	// ResendInterval keeps the previous token of the same type if it was created more recently, returning ErrTokenTooSoon
+	ResendInterval time.Duration
+}
+
+// CreateOneTimeToken creates a token of the type for the user, replacing the previous token of the same type.
+// Only the hash of the token is stored, the token itself is returned to be delivered to the user.
+func CreateOneTimeToken(queries *schema.Queries, ctx context.Context, config *conf.SurgeConfigurations, user *schema.AuthUser, options CreateOneTimeTokenOptions) (string, error) {
+	if options.ResendInterval > 0 {
+		previous, err := queries.GetOneTimeTokenOfUser(ctx, schema.GetOneTimeTokenOfUserParams{
+			UserID:    user.ID,
+			TokenType: options.Type,
+		})
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			logrus.WithError(err).Error(ErrDatabaseJob)
+			return "", ErrDatabaseJob
+		}
+		if err == nil && time.Since(previous.CreatedAt) < options.ResendInterval {
+			return "", ErrTokenTooSoon
+		}
+	}
+
+	token := utilities.SecureToken(utilities.WithLength(oneTimeTokenLength))
+	if options.Numeric {
+		token = utilities.SecureDigits(oneTimeCodeDigits)
+	}
+
+	_, err := queries.CreateOneTimeToken(ctx, schema.CreateOneTimeTokenParams{
+		UserID:    user.ID,
+		TokenType: options.Type,
+		TokenHash: hashOneTimeToken(config, token),
+		RelatesTo: options.RelatesTo,
+		ExpiresAt: time.Now().Add(options.ExpiresAfter),
+	})
+	if err != nil {
+		logrus.WithError(err).Error(ErrDatabaseJob)
+		return "", ErrDatabaseJob
+	}
+
+	return token, nil
+}
+
+// ConsumeOneTimeToken looks up the token of the type and deletes it, so it can't be used again
+func ConsumeOneTimeToken(queries *schema.Queries, ctx context.Context, config *conf.SurgeConfigurations, tokenType OneTimeTokenType, token string) (*schema.AuthOneTimeToken, error) {
+	oneTimeToken, err := queries.GetOneTimeToken(ctx, schema.GetOneTimeTokenParams{
+		TokenType: tokenType,
+		TokenHash: hashOneTimeToken(config, token),
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTokenNotFound
+		}
+		return nil, ErrDatabaseJob
+	}
+
+	if time.Now().After(oneTimeToken.ExpiresAt) {
+		return nil, ErrTokenExpired
+	}
+
+	if err := queries.DeleteOneTimeToken(ctx, oneTimeToken.ID); err != nil {
+		return nil, ErrDatabaseJob
+	}
+
+	return oneTimeToken, nil
+}
+
+// FindOneTimeToken looks up the token of the type without consuming it
+func FindOneTimeToken(queries *schema.Queries, ctx context.Context, config *conf.SurgeConfigurations, tokenType OneTimeTokenType, token string) (*schema.AuthOneTimeToken, error) {
+	oneTimeToken, err := queries.GetOneTimeToken(ctx, schema.GetOneTimeTokenParams{
+		TokenType: tokenType,
+		TokenHash: hashOneTimeToken(config, token),
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTokenNotFound
+		}
+		return nil, ErrDatabaseJob
+	}
+
+	if time.Now().After(oneTimeToken.ExpiresAt) {
+		return nil, ErrTokenExpired
+	}
+
+	return oneTimeToken, nil
+}
+
+// ConsumeOneTimeTokenOfUser looks up the token of the type issued to the user and deletes it, so it can't be used again.
+// Numeric codes are not unique among users and have to be consumed this way.
+// Wrong codes are counted even though ErrWrongCode is returned, so the caller has to commit them.
+func ConsumeOneTimeTokenOfUser(queries *schema.Queries, ctx context.Context, config *conf.SurgeConfigurations, user *schema.AuthUser, tokenType OneTimeTokenType, token string) (*schema.AuthOneTimeToken, error) {
+	oneTimeToken, err := queries.GetOneTimeTokenOfUser(ctx, schema.GetOneTimeTokenOfUserParams{
+		UserID:    user.ID,
+		TokenType: tokenType,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTokenNotFound
+		}
+		return nil, ErrDatabaseJob
+	}
+
+	if time.Now().After(oneTimeToken.ExpiresAt) {
+		return nil, ErrTokenExpired
+	}
+
+	if !hmac.Equal([]byte(oneTimeToken.TokenHash), []byte(hashOneTimeToken(config, token))) {
+		attempts, err := queries.IncrementOneTimeTokenFailedAttempts(ctx, oneTimeToken.ID)
+		if err != nil {
+			logrus.WithError(err).Error(ErrDatabaseJob)
+			return nil, ErrDatabaseJob
+		}
+
+		if attempts >= maxOneTimeCodeAttempts {
+			if err := queries.DeleteOneTimeToken(ctx, oneTimeToken.ID); err != nil {
+				return nil, ErrDatabaseJob
+			}
+		}
+		return nil, ErrWrongCode
+	}
+
+	if err := queries.DeleteOneTimeToken(ctx, oneTimeToken.ID); err != nil {
+		return nil, ErrDatabaseJob
+	}
+
+	return oneTimeToken, nil
+}
+
+func hashOneTimeToken(config *conf.SurgeConfigurations, token string) string {
+	return utilities.HashToken(token, []byte(config.JWT.Secret))
+}
@@ -6,12 +6,15 @@ import (
 	"encoding/json"
 	"errors"
 	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
+	"github.com/sqlc-dev/pqtype"
 	"golang.org/x/crypto/bcrypt"
 	"surge/internal/api/provider"
 	"surge/internal/conf"
 	"surge/internal/schema"
 	"surge/internal/storage"
+	"surge/internal/utilities"
 	"time"
 )
 
@@ -23,16 +26,23 @@ type UserMetadata struct {
 	Extra     map[string]interface{}
 }
 
+// maxPasswordBytes is the longest password bcrypt hashes, longer ones would be truncated
+const maxPasswordBytes = 72
+
 type CreateUserOptions struct {
 	Email    *string `validate:"email,lte=255"`
 	Username *string `validate:"gte=3,lte=20"`
 	Phone    *string `validate:"e164"`
-	Password *string `validate:"required,gte=8,lte=255"`
+	// Password is the plain password, it is hashed once validated
+	Password *string `validate:"required,gte=8,lte=72"`
 	Metadata UserMetadata
+	// OneTimePassword marks users signing up with a one-time password, who can only provide the email or phone
+	// the password is sent to, so the fields required for signing up with credentials are not required from them
+	OneTimePassword bool
 }
 
 func (o CreateUserOptions) validate() error {
-	if o.Email == nil && o.Username == nil {
+	if o.Email == nil && o.Username == nil && o.Phone == nil {
 		return ErrMissingField
 	}
 
@@ -42,6 +52,40 @@ func (o CreateUserOptions) validate() error {
 	if o.Email == nil {
 		fieldsToExclude = append(fieldsToExclude, "Email")
 	}
+	if o.Username == nil {
+		fieldsToExclude = append(fieldsToExclude, "Username")
+	}
+	if o.Phone == nil {
+		fieldsToExclude = append(fieldsToExclude, "Phone")
+	}
+	// Users signing in without password (e.g. one-time passwords) don't have one
+	if o.Password == nil {
+		fieldsToExclude = append(fieldsToExclude, "Password")
+	} else if len(*o.Password) > maxPasswordBytes {
+		return ErrInvalidPassword
+	}
+
+	return validate.StructExcept(o, fieldsToExclude...)
+}
+
+// UpdateUserOptions describes fields to update, nil fields are left unchanged
+type UpdateUserOptions struct {
+	Email    *string `validate:"email,lte=255"`
+	Username *string `validate:"gte=3,lte=20"`
+	Phone    *string `validate:"e164"`
+	Metadata UserMetadata
+}
+
+func (o UpdateUserOptions) validate() error {
+	validate := validator.New()
+
+	var fieldsToExclude []string
+	if o.Email == nil {
+		fieldsToExclude = append(fieldsToExclude, "Email")
+	}
+	if o.Username == nil {
+		fieldsToExclude = append(fieldsToExclude, "Username")
+	}
 	if o.Phone == nil {
 		fieldsToExclude = append(fieldsToExclude, "Phone")
 	}
@@ -59,6 +103,9 @@ func (o CreateUserOptions) validateWithConfig(config *conf.SurgeConfigurations)
 	if err := o.validate(); err != nil {
 		return err
 	}
+	if o.OneTimePassword {
+		return nil
+	}
 
 	if config.Auth.CredentialsRequireEmail && o.Email == nil {
 		return ErrRequiredEmail
@@ -78,29 +125,39 @@ func CreateUser(queries *schema.Queries, ctx context.Context, config *conf.Surge
 		return nil, err
 	}
 
-	if _, err := queries.GetUserByEmail(ctx, *options.Email); options.Email != nil {
-		if err == nil {
-			return nil, ErrDuplicateEmail
+	if options.Email != nil {
+		found, err := queries.GetUserByEmail(ctx, *options.Email)
+		if err := checkUniqueField(nil, found, err, ErrDuplicateEmail); err != nil {
+			return nil, err
 		}
-		if !errors.Is(err, sql.ErrNoRows) {
-			return nil, ErrDatabaseJob
+	}
+	if options.Username != nil {
+		found, err := queries.GetUserByUsername(ctx, *options.Username)
+		if err := checkUniqueField(nil, found, err, ErrDuplicateUsername); err != nil {
+			return nil, err
 		}
 	}
-
-	if _, err := queries.GetUserByUsername(ctx, *options.Username); options.Email != nil {
-		if err == nil {
-			return nil, ErrDuplicateUsername
+	if options.Phone != nil {
+		found, err := queries.GetUserByPhone(ctx, *options.Phone)
+		if err := checkUniqueField(nil, found, err, ErrDuplicatePhone); err != nil {
+			return nil, err
 		}
-		if !errors.Is(err, sql.ErrNoRows) {
-			return nil, ErrDuplicatePhone
+	}
+
+	var hashedPassword *string
+	if options.Password != nil {
+		hashed, err := HashPassword(*options.Password)
+		if err != nil {
+			return nil, err
 		}
+		hashedPassword = &hashed
 	}
 
 	result, err := queries.CreateUser(ctx, schema.CreateUserParams{
 		Phone:             storage.NewNullableString(options.Phone),
 		Email:             storage.NewNullableString(options.Email),
 		Username:          storage.NewNullableString(options.Username),
-		EncryptedPassword: storage.NewNullableString(options.Password),
+		EncryptedPassword: storage.NewNullableString(hashedPassword),
 
 		MetaAvatar:    storage.NewNullableString(options.Metadata.Avatar),
 		MetaFirstName: storage.NewNullableString(options.Metadata.FirstName),
@@ -116,6 +173,304 @@ func CreateUser(queries *schema.Queries, ctx context.Context, config *conf.Surge
 	return result, err
 }
 
+// UpdateUser updates the user with the options, applying the same validation rules CreateUser does
+func UpdateUser(queries *schema.Queries, ctx context.Context, user *schema.AuthUser, options UpdateUserOptions) (*schema.AuthUser, error) {
+	if err := options.validate(); err != nil {
+		return nil, err
+	}
+
+	if options.Email != nil {
+		found, err := queries.GetUserByEmail(ctx, *options.Email)
+		if err := checkUniqueField(user, found, err, ErrDuplicateEmail); err != nil {
+			return nil, err
+		}
+	}
+	if options.Username != nil {
+		found, err := queries.GetUserByUsername(ctx, *options.Username)
+		if err := checkUniqueField(user, found, err, ErrDuplicateUsername); err != nil {
+			return nil, err
+		}
+	}
+	if options.Phone != nil {
+		found, err := queries.GetUserByPhone(ctx, *options.Phone)
+		if err := checkUniqueField(user, found, err, ErrDuplicatePhone); err != nil {
+			return nil, err
+		}
+	}
+
+	var phone interface{}
+	if options.Phone != nil {
+		phone = *options.Phone
+	}
+
+	user, err := queries.UpdateUser(ctx, schema.UpdateUserParams{
+		ID:       user.ID,
+		Email:    storage.NewNullableString(options.Email),
+		Username: storage.NewNullableString(options.Username),
+		Phone:    phone,
+	})
+	if err != nil {
+		logrus.WithError(err).Error(ErrDatabaseJob)
+		return nil, ErrDatabaseJob
+	}
+
+	metaExtra := pqtype.NullRawMessage{}
+	if options.Metadata.Extra != nil {
+		var extra map[string]interface{}
+		if err := json.Unmarshal(user.MetaExtra, &extra); err != nil {
+			return nil, err
+		}
+
+		merged, err := json.Marshal(utilities.MergeJSONPatch(extra, options.Metadata.Extra))
+		if err != nil {
+			return nil, err
+		}
+		metaExtra = pqtype.NullRawMessage{RawMessage: merged, Valid: true}
+	}
+
+	user, err = queries.UpdateUserMetadata(ctx, schema.UpdateUserMetadataParams{
+		ID:            user.ID,
+		MetaAvatar:    storage.NewNullableString(options.Metadata.Avatar),
+		MetaFirstName: storage.NewNullableString(options.Metadata.FirstName),
+		MetaLastName:  storage.NewNullableString(options.Metadata.LastName),
+		MetaBirthdate: storage.NewNullableTime(options.Metadata.Birthdate),
+		MetaExtra:     metaExtra,
+	})
+	if err != nil {
+		logrus.WithError(err).Error(ErrDatabaseJob)
+		return nil, ErrDatabaseJob
+	}
+
+	return user, nil
+}
+
+// checkUniqueField returns duplicateErr if looking up an unique field found a user other than self, self is nil for new users
+func checkUniqueField(self *schema.AuthUser, found *schema.AuthUser, err error, duplicateErr error) error {
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return ErrDatabaseJob
+	}
+	if self == nil || found.ID != self.ID {
+		return duplicateErr
+	}
+	return nil
+}
+
+type ChangePasswordOptions struct {
+	CurrentPassword string
+	NewPassword     string
+}
+
+// ChangePassword verifies the current password of the user and replaces it with the new one
+func ChangePassword(queries *schema.Queries, ctx context.Context, user *schema.AuthUser, options ChangePasswordOptions) (*schema.AuthUser, error) {
+	if !user.EncryptedPassword.Valid {
+		return nil, ErrNoPassword
+	}
+	if !AuthenticateUser(user, options.CurrentPassword) {
+		return nil, ErrWrongPassword
+	}
+
+	return SetPassword(queries, ctx, user, options.NewPassword)
+}
+
+type setPasswordOptions struct {
+	Password string `validate:"required,gte=8,lte=72"`
+}
+
+// SetPassword replaces password of the user without verifying the current one
+func SetPassword(queries *schema.Queries, ctx context.Context, user *schema.AuthUser, password string) (*schema.AuthUser, error) {
+	if len(password) > maxPasswordBytes {
+		return nil, ErrInvalidPassword
+	}
+	if err := validator.New().Struct(setPasswordOptions{Password: password}); err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err = queries.UpdateUser(ctx, schema.UpdateUserParams{
+		ID:                user.ID,
+		EncryptedPassword: storage.NewString(hashedPassword),
+	})
+	if err != nil {
+		logrus.WithError(err).Error(ErrDatabaseJob)
+		return nil, ErrDatabaseJob
+	}
+
+	return user, nil
+}
+
+// SetEmailConfirmed marks email of the user as confirmed or unconfirmed
+func SetEmailConfirmed(queries *schema.Queries, ctx context.Context, user *schema.AuthUser, confirmed bool) (*schema.AuthUser, error) {
+	confirmedAt := storage.NewTimeNull()
+	if confirmed {
+		confirmedAt = storage.NewTime(time.Now())
+	}
+
+	user, err := queries.UpdateUserEmailConfirmedAt(ctx, schema.UpdateUserEmailConfirmedAtParams{
+		ID:               user.ID,
+		EmailConfirmedAt: confirmedAt,
+	})
+	if err != nil {
+		logrus.WithError(err).Error(ErrDatabaseJob)
+		return nil, ErrDatabaseJob
+	}
+
+	return user, nil
+}
+
+// SetEmailChange stores email as the pending new email of the user, it only replaces the email once ConfirmEmailChange is called
+func SetEmailChange(queries *schema.Queries, ctx context.Context, user *schema.AuthUser, email string) (*schema.AuthUser, error) {
+	if err := (UpdateUserOptions{Email: &email}).validate(); err != nil {
+		return nil, err
+	}
+
+	found, err := queries.GetUserByEmail(ctx, email)
+	if err := checkUniqueField(user, found, err, ErrDuplicateEmail); err != nil {
+		return nil, err
+	}
+
+	user, err = queries.UpdateUserEmailChange(ctx, schema.UpdateUserEmailChangeParams{
+		ID:          user.ID,
+		EmailChange: storage.NewString(email),
+	})
+	if err != nil {
+		logrus.WithError(err).Error(ErrDatabaseJob)
+		return nil, ErrDatabaseJob
+	}
+
+	return user, nil
+}
+
+// ConfirmEmailChange replaces email of the user with the pending new email, which is confirmed by doing so
+func ConfirmEmailChange(queries *schema.Queries, ctx context.Context, user *schema.AuthUser) (*schema.AuthUser, error) {
+	// Another user may have taken the email since the change was requested
+	found, err := queries.GetUserByEmail(ctx, user.EmailChange.String)
+	if err := checkUniqueField(user, found, err, ErrDuplicateEmail); err != nil {
+		return nil, err
+	}
+
+	user, err = queries.ConfirmUserEmailChange(ctx, user.ID)
+	if err != nil {
+		logrus.WithError(err).Error(ErrDatabaseJob)
+		return nil, ErrDatabaseJob
+	}
+
+	return user, nil
+}
+
+// SetPhoneConfirmed marks phone of the user as confirmed or unconfirmed
+func SetPhoneConfirmed(queries *schema.Queries, ctx context.Context, user *schema.AuthUser, confirmed bool) (*schema.AuthUser, error) {
+	confirmedAt := storage.NewTimeNull()
+	if confirmed {
+		confirmedAt = storage.NewTime(time.Now())
+	}
+
+	user, err := queries.UpdateUserPhoneConfirmedAt(ctx, schema.UpdateUserPhoneConfirmedAtParams{
+		ID:               user.ID,
+		PhoneConfirmedAt: confirmedAt,
+	})
+	if err != nil {
+		logrus.WithError(err).Error(ErrDatabaseJob)
+		return nil, ErrDatabaseJob
+	}
+
+	return user, nil
+}
+
+// BanUser bans the user until the given time and revokes every session of the user, nil lifts the ban
+func BanUser(queries *schema.Queries, ctx context.Context, user *schema.AuthUser, until *time.Time) (*schema.AuthUser, error) {
+	user, err := queries.UpdateUserBannedUntil(ctx, schema.UpdateUserBannedUntilParams{
+		ID:          user.ID,
+		BannedUntil: storage.NewNullableTime(until),
+	})
+	if err != nil {
+		logrus.WithError(err).Error(ErrDatabaseJob)
+		return nil, ErrDatabaseJob
+	}
+
+	if until == nil {
+		return user, nil
+	}
+
+	if err := queries.RevokeRefreshTokensOfUser(ctx, uuid.NullUUID{UUID: user.ID, Valid: true}); err != nil {
+		logrus.WithError(err).Error(ErrDatabaseJob)
+		return nil, ErrDatabaseJob
+	}
+	if err := queries.DeleteSessionsOfUser(ctx, user.ID); err != nil {
+		logrus.WithError(err).Error(ErrDatabaseJob)
+		return nil, ErrDatabaseJob
+	}
+
+	return user, nil
+}
+
+// IsUserBanned reports whether the user is banned at the moment
+func IsUserBanned(user *schema.AuthUser) bool {
+	return user.BannedUntil.Valid && time.Now().Before(user.BannedUntil.Time)
+}
+
+// DeleteUser deletes the user, everything belonging to the user is deleted along by the foreign keys
+func DeleteUser(queries *schema.Queries, ctx context.Context, user *schema.AuthUser) error {
+	if err := queries.DeleteUser(ctx, user.ID); err != nil {
+		logrus.WithError(err).Error(ErrDatabaseJob)
+		return ErrDatabaseJob
+	}
+
+	return nil
+}
+
+// SoftDeleteUser anonymizes the user and deletes every way to sign in as the user, keeping only the row itself
+func SoftDeleteUser(queries *schema.Queries, ctx context.Context, user *schema.AuthUser) (*schema.AuthUser, error) {
+	cleanups := []func() error{
+		func() error { return queries.DeleteIdentitiesOfUser(ctx, user.ID) },
+		func() error { return queries.DeleteRefreshTokensOfUser(ctx, uuid.NullUUID{UUID: user.ID, Valid: true}) },
+		func() error { return queries.DeleteSessionsOfUser(ctx, user.ID) },
+		func() error { return queries.DeleteFactorsOfUser(ctx, user.ID) },
+		func() error { return queries.DeleteRecoveryCodesOfUser(ctx, user.ID) },
+		func() error { return queries.DeleteOneTimeTokensOfUser(ctx, user.ID) },
+	}
+	for _, cleanup := range cleanups {
+		if err := cleanup(); err != nil {
+			logrus.WithError(err).Error(ErrDatabaseJob)
+			return nil, ErrDatabaseJob
+		}
+	}
+
+	user, err := queries.SoftDeleteUser(ctx, user.ID)
+	if err != nil {
+		logrus.WithError(err).Error(ErrDatabaseJob)
+		return nil, ErrDatabaseJob
+	}
+
+	return user, nil
+}
+
+// PurgeDeletedUsers deletes users which were soft deleted before the given time, returning how many were deleted
+func PurgeDeletedUsers(queries *schema.Queries, ctx context.Context, deletedBefore time.Time) (int64, error) {
+	count, err := queries.PurgeDeletedUsers(ctx, deletedBefore)
+	if err != nil {
+		logrus.WithError(err).Error(ErrDatabaseJob)
+		return 0, ErrDatabaseJob
+	}
+
+	return count, nil
+}
+
+// HashPassword hashes the password to be stored as encrypted_password
+func HashPassword(password string) (string, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashedPassword[:]), nil
+}
+
 func AuthenticateUser(user *schema.AuthUser, password string) bool {
 	if !user.EncryptedPassword.Valid {
 		return false
@@ -123,12 +478,28 @@ func AuthenticateUser(user *schema.AuthUser, password string) bool {
 	return bcrypt.CompareHashAndPassword([]byte(user.EncryptedPassword.String), []byte(password)) == nil
 }
 
+// CreateUserAndIdentity creates a user with the email, username and profile reported by the provider.
+// The email is only stored confirmed if the provider verified it, otherwise it has to be confirmed by the user.
+// The email and username are left empty if they are invalid or already used by another user.
 func CreateUserAndIdentity(queries *schema.Queries, ctx context.Context, options CreateUserAndIdentityOptions) (*schema.AuthUser, *schema.AuthIdentity, error) {
-	firstName := options.ProviderData.Claims.GivenName
-	lastName := options.ProviderData.Claims.FamilyName
-	avatarUrl := options.ProviderData.Claims.Picture
+	claims := options.ProviderData.Claims
+
+	firstName := claims.GivenName
+	lastName := claims.FamilyName
+	avatarUrl := claims.Picture
+
+	email, err := getAvailableExternalField(queries.GetUserByEmail, ctx, claims.Email, UpdateUserOptions{Email: &claims.Email})
+	if err != nil {
+		return nil, nil, err
+	}
+	username, err := getAvailableExternalField(queries.GetUserByUsername, ctx, claims.PreferredUsername, UpdateUserOptions{Username: &claims.PreferredUsername})
+	if err != nil {
+		return nil, nil, err
+	}
 
 	user, err := queries.CreateUser(ctx, schema.CreateUserParams{
+		Email:         storage.NewNullableString(email),
+		Username:      storage.NewNullableString(username),
 		MetaFirstName: sql.NullString{String: firstName, Valid: firstName != ""},
 		MetaLastName:  sql.NullString{String: lastName, Valid: lastName != ""},
 		MetaAvatar:    sql.NullString{String: avatarUrl, Valid: avatarUrl != ""},
@@ -138,20 +509,84 @@ func CreateUserAndIdentity(queries *schema.Queries, ctx context.Context, options
 		return nil, nil, err
 	}
 
-	marshalledJson, err := json.Marshal(options.ProviderData)
+	if email != nil && claims.EmailVerified {
+		user, err = SetEmailConfirmed(queries, ctx, user, true)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	identity, err := CreateIdentity(queries, ctx, user, options)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	identity, err := queries.CreateIdentityWithUser(ctx, schema.CreateIdentityWithUserParams{
+	return user, identity, nil
+}
+
+// getAvailableExternalField returns the value reported by a provider if options holding only the value are valid
+// and find finds no user with it, nil otherwise
+func getAvailableExternalField(find func(context.Context, string) (*schema.AuthUser, error), ctx context.Context, value string, options UpdateUserOptions) (*string, error) {
+	if value == "" || options.validate() != nil {
+		return nil, nil
+	}
+
+	_, err := find(ctx, value)
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		logrus.WithError(err).Error(ErrDatabaseJob)
+		return nil, ErrDatabaseJob
+	}
+
+	return &value, nil
+}
+
+// SyncUserProfile updates profile fields of the user with the claims of the provider according to the policy,
+// one of conf.ProfileSyncPolicyNever, conf.ProfileSyncPolicyFillEmpty or conf.ProfileSyncPolicyAlways
+func SyncUserProfile(queries *schema.Queries, ctx context.Context, user *schema.AuthUser, policy string, claims *provider.UserClaims) (*schema.AuthUser, error) {
+	if policy == conf.ProfileSyncPolicyNever {
+		return user, nil
+	}
+
+	syncField := func(current sql.NullString, value string) sql.NullString {
+		if value == "" || (policy == conf.ProfileSyncPolicyFillEmpty && current.Valid) {
+			return sql.NullString{}
+		}
+		return storage.NewString(value)
+	}
+
+	params := schema.UpdateUserMetadataParams{
+		ID:            user.ID,
+		MetaFirstName: syncField(user.MetaFirstName, claims.GivenName),
+		MetaLastName:  syncField(user.MetaLastName, claims.FamilyName),
+		MetaAvatar:    syncField(user.MetaAvatar, claims.Picture),
+	}
+	if !params.MetaFirstName.Valid && !params.MetaLastName.Valid && !params.MetaAvatar.Valid {
+		return user, nil
+	}
+
+	user, err := queries.UpdateUserMetadata(ctx, params)
+	if err != nil {
+		logrus.WithError(err).Error(ErrDatabaseJob)
+		return nil, ErrDatabaseJob
+	}
+
+	return user, nil
+}
+
+// CreateIdentity links the external identity described by options to the existing user
+func CreateIdentity(queries *schema.Queries, ctx context.Context, user *schema.AuthUser, options CreateUserAndIdentityOptions) (*schema.AuthIdentity, error) {
+	marshalledJson, err := json.Marshal(options.ProviderData)
+	if err != nil {
+		return nil, err
+	}
+
+	return queries.CreateIdentityWithUser(ctx, schema.CreateIdentityWithUserParams{
 		UserID:       user.ID,
 		Provider:     options.Provider,
 		ProviderID:   options.ProviderAccountID,
 		ProviderData: marshalledJson,
 	})
-	if err != nil {
-		return nil, nil, err
-	}
-
-	return user, identity, nil
 }
@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"surge/internal/conf"
+	"testing"
+)
+
+func TestCreateUserOptionsValidatePassword(t *testing.T) {
+	email := "user@surge.test"
+
+	tests := []struct {
+		name        string
+		password    string
+		expectError bool
+	}{
+		{name: "valid password", password: "correct horse"},
+		{name: "too short", password: "a", expectError: true},
+		{name: "longest password bcrypt hashes", password: strings.Repeat("a", maxPasswordBytes)},
+		{name: "longer than bcrypt hashes", password: strings.Repeat("a", maxPasswordBytes+1), expectError: true},
+		// Multi-byte characters fit the character limit but not the byte limit of bcrypt
+		{name: "too many bytes", password: strings.Repeat("é", 40), expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CreateUserOptions{Email: &email, Password: &tt.password}.validate()
+			if tt.expectError && err == nil {
+				t.Fatal("expected the password to be rejected")
+			}
+			if !tt.expectError && err != nil {
+				t.Fatalf("failed to validate password: %v", err)
+			}
+		})
+	}
+}
+
+func TestCreateUserOptionsValidateWithoutPassword(t *testing.T) {
+	email := "user@surge.test"
+
+	if err := (CreateUserOptions{Email: &email}).validate(); err != nil {
+		t.Fatalf("failed to validate user without password: %v", err)
+	}
+	if err := (CreateUserOptions{}).validate(); !errors.Is(err, ErrMissingField) {
+		t.Fatalf("error = %v, want %v", err, ErrMissingField)
+	}
+}
+
+func TestCreateUserOptionsValidateWithConfig(t *testing.T) {
+	email := "user@surge.test"
+	phone := "+15555550100"
+	password := "correct horse"
+
+	config := &conf.SurgeConfigurations{}
+	config.Auth.CredentialsRequireEmail = true
+	config.Auth.CredentialsRequirePhone = true
+	config.Auth.CredentialsRequireUsername = true
+
+	tests := []struct {
+		name        string
+		options     CreateUserOptions
+		expectError error
+	}{
+		{
+			name:        "credentials without username",
+			options:     CreateUserOptions{Email: &email, Phone: &phone, Password: &password},
+			expectError: ErrRequiredUsername,
+		},
+		{
+			name:    "email one-time password",
+			options: CreateUserOptions{Email: &email, OneTimePassword: true},
+		},
+		{
+			name:    "phone one-time password",
+			options: CreateUserOptions{Phone: &phone, OneTimePassword: true},
+		},
+		{
+			name:        "one-time password without email or phone",
+			options:     CreateUserOptions{OneTimePassword: true},
+			expectError: ErrMissingField,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.options.validateWithConfig(config)
+			if tt.expectError == nil && err != nil {
+				t.Fatalf("failed to validate user: %v", err)
+			}
+			if tt.expectError != nil && !errors.Is(err, tt.expectError) {
+				t.Fatalf("error = %v, want %v", err, tt.expectError)
+			}
+		})
+	}
+}
@@ -0,0 +1,312 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"surge/internal/conf"
+	"surge/internal/schema"
+	"surge/internal/storage"
+	"time"
+)
+
+// WebAuthnUser adapts the user and their credentials to webauthn.User, the user handle is the user id
+type WebAuthnUser struct {
+	user        *schema.AuthUser
+	credentials []webauthn.Credential
+}
+
+// NewWebAuthnUser creates a WebAuthnUser with the stored credentials of the user
+func NewWebAuthnUser(user *schema.AuthUser, credentials []*schema.AuthWebauthnCredential) (*WebAuthnUser, error) {
+	webAuthnUser := &WebAuthnUser{user: user}
+	for _, stored := range credentials {
+		var credential webauthn.Credential
+		if err := json.Unmarshal(stored.Credential, &credential); err != nil {
+			return nil, err
+		}
+		webAuthnUser.credentials = append(webAuthnUser.credentials, credential)
+	}
+	return webAuthnUser, nil
+}
+
+func (u *WebAuthnUser) WebAuthnID() []byte {
+	return u.user.ID[:]
+}
+
+func (u *WebAuthnUser) WebAuthnName() string {
+	if u.user.Email.Valid {
+		return u.user.Email.String
+	}
+	if phone := storage.InterfaceToStringPointer(u.user.Phone); phone != nil {
+		return *phone
+	}
+	if u.user.Username.Valid {
+		return u.user.Username.String
+	}
+	return u.user.ID.String()
+}
+
+func (u *WebAuthnUser) WebAuthnDisplayName() string {
+	if u.user.MetaFirstName.Valid {
+		return u.user.MetaFirstName.String
+	}
+	return u.WebAuthnName()
+}
+
+func (u *WebAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.credentials
+}
+
+func (u *WebAuthnUser) WebAuthnIcon() string {
+	return ""
+}
+
+// Descriptors lists the credentials of the user, e.g. to exclude them from being registered again
+func (u *WebAuthnUser) Descriptors() []protocol.CredentialDescriptor {
+	descriptors := make([]protocol.CredentialDescriptor, len(u.credentials))
+	for i, credential := range u.credentials {
+		descriptors[i] = credential.Descriptor()
+	}
+	return descriptors
+}
+
+// EnrollWebAuthnFactor creates an unverified webauthn factor of the user,
+// the credential is registered by challenging and verifying the factor
+func EnrollWebAuthnFactor(queries *schema.Queries, ctx context.Context, user *schema.AuthUser, friendlyName *string) (*schema.AuthMfaFactor, error) {
+	factor, err := queries.CreateFactor(ctx, schema.CreateFactorParams{
+		UserID:       user.ID,
+		FactorType:   FactorTypeWebAuthn,
+		Status:       FactorStatusUnverified,
+		FriendlyName: storage.NewNullableString(friendlyName),
+	})
+	if err != nil {
+		logrus.WithError(err).Error(ErrDatabaseJob)
+		return nil, ErrDatabaseJob
+	}
+
+	return factor, nil
+}
+
+// BeginWebAuthnFactorChallenge starts the ceremony of the webauthn factor, which is registering the credential
+// for unverified factors and asserting the registered credential for verified factors.
+// The returned options are passed to navigator.credentials.create() or navigator.credentials.get() respectively.
+func BeginWebAuthnFactorChallenge(queries *schema.Queries, ctx context.Context, webAuthn *webauthn.WebAuthn, user *schema.AuthUser, factor *schema.AuthMfaFactor) (interface{}, *webauthn.SessionData, error) {
+	if factor.Status != FactorStatusVerified {
+		credentials, err := queries.ListWebAuthnCredentialsOfUser(ctx, user.ID)
+		if err != nil {
+			return nil, nil, ErrDatabaseJob
+		}
+		webAuthnUser, err := NewWebAuthnUser(user, credentials)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return webAuthn.BeginRegistration(webAuthnUser,
+			webauthn.WithExclusions(webAuthnUser.Descriptors()),
+			webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementPreferred),
+		)
+	}
+
+	webAuthnUser, err := getWebAuthnUserOfFactor(queries, ctx, user, factor)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return webAuthn.BeginLogin(webAuthnUser)
+}
+
+// VerifyWebAuthnChallenge validates the credential created or asserted in the ceremony of the challenge,
+// so the challenge can't be verified again. Unverified factors become verified with the created credential.
+func VerifyWebAuthnChallenge(queries *schema.Queries, ctx context.Context, config *conf.SurgeConfigurations, webAuthn *webauthn.WebAuthn, user *schema.AuthUser, factor *schema.AuthMfaFactor, challengeId uuid.UUID, response json.RawMessage) (*schema.AuthMfaFactor, error) {
+	challenge, err := getPendingChallenge(queries, ctx, config, factor, challengeId)
+	if err != nil {
+		return nil, err
+	}
+
+	var sessionData webauthn.SessionData
+	if err := json.Unmarshal(challenge.WebauthnSessionData.RawMessage, &sessionData); err != nil {
+		return nil, ErrChallengeNotFound
+	}
+
+	if factor.Status != FactorStatusVerified {
+		parsed, err := protocol.ParseCredentialCreationResponseBody(bytes.NewReader(response))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrWebAuthnFailed, err)
+		}
+
+		credential, err := webAuthn.CreateCredential(&WebAuthnUser{user: user}, sessionData, parsed)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrWebAuthnFailed, err)
+		}
+
+		marshalled, err := json.Marshal(credential)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = queries.CreateWebAuthnCredential(ctx, schema.CreateWebAuthnCredentialParams{
+			FactorID:     factor.ID,
+			UserID:       user.ID,
+			CredentialID: credential.ID,
+			Credential:   marshalled,
+		})
+		if err != nil {
+			logrus.WithError(err).Error(ErrDatabaseJob)
+			return nil, ErrDatabaseJob
+		}
+
+		return completeChallenge(queries, ctx, factor, challenge)
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBody(bytes.NewReader(response))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrWebAuthnFailed, err)
+	}
+
+	stored, err := queries.GetWebAuthnCredentialByFactor(ctx, factor.ID)
+	if err != nil {
+		return nil, ErrDatabaseJob
+	}
+	webAuthnUser, err := NewWebAuthnUser(user, []*schema.AuthWebauthnCredential{stored})
+	if err != nil {
+		return nil, err
+	}
+
+	credential, err := webAuthn.ValidateLogin(webAuthnUser, sessionData, parsed)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrWebAuthnFailed, err)
+	}
+
+	if err := updateWebAuthnCredential(queries, ctx, stored, credential); err != nil {
+		return nil, err
+	}
+
+	return completeChallenge(queries, ctx, factor, challenge)
+}
+
+// BeginWebAuthnSignIn starts the ceremony of signing in with a passkey of any user,
+// the returned options are passed to navigator.credentials.get()
+func BeginWebAuthnSignIn(queries *schema.Queries, ctx context.Context, webAuthn *webauthn.WebAuthn) (*schema.AuthWebauthnChallenge, *protocol.CredentialAssertion, error) {
+	// Passkeys are used without another factor, so the user has to be verified by the authenticator
+	assertion, sessionData, err := webAuthn.BeginDiscoverableLogin(webauthn.WithUserVerification(protocol.VerificationRequired))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	marshalled, err := json.Marshal(sessionData)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	challenge, err := queries.CreateWebAuthnChallenge(ctx, marshalled)
+	if err != nil {
+		logrus.WithError(err).Error(ErrDatabaseJob)
+		return nil, nil, ErrDatabaseJob
+	}
+
+	return challenge, assertion, nil
+}
+
+// FinishWebAuthnSignIn consumes the sign in challenge and validates the asserted passkey, returning the user it belongs to
+func FinishWebAuthnSignIn(queries *schema.Queries, ctx context.Context, config *conf.SurgeConfigurations, webAuthn *webauthn.WebAuthn, challengeId uuid.UUID, response json.RawMessage) (*schema.AuthUser, error) {
+	challenge, err := queries.GetWebAuthnChallenge(ctx, challengeId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrChallengeNotFound
+		}
+		return nil, ErrDatabaseJob
+	}
+
+	if err := queries.DeleteWebAuthnChallenge(ctx, challenge.ID); err != nil {
+		return nil, ErrDatabaseJob
+	}
+
+	if time.Since(challenge.CreatedAt) > time.Second*time.Duration(config.WebAuthn.ChallengeExpiresAfter) {
+		return nil, ErrChallengeExpired
+	}
+
+	var sessionData webauthn.SessionData
+	if err := json.Unmarshal(challenge.SessionData, &sessionData); err != nil {
+		return nil, ErrChallengeNotFound
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBody(bytes.NewReader(response))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrWebAuthnFailed, err)
+	}
+
+	var user *schema.AuthUser
+	var credentials []*schema.AuthWebauthnCredential
+	handler := func(rawID, userHandle []byte) (webauthn.User, error) {
+		userId, err := uuid.FromBytes(userHandle)
+		if err != nil {
+			return nil, err
+		}
+
+		user, err = queries.GetUser(ctx, userId)
+		if err != nil {
+			return nil, err
+		}
+
+		credentials, err = queries.ListWebAuthnCredentialsOfUser(ctx, user.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewWebAuthnUser(user, credentials)
+	}
+
+	credential, err := webAuthn.ValidateDiscoverableLogin(handler, sessionData, parsed)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrWebAuthnFailed, err)
+	}
+
+	for _, stored := range credentials {
+		if bytes.Equal(stored.CredentialID, credential.ID) {
+			if err := updateWebAuthnCredential(queries, ctx, stored, credential); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+
+	return user, nil
+}
+
+// getWebAuthnUserOfFactor creates a WebAuthnUser with only the credential of the factor
+func getWebAuthnUserOfFactor(queries *schema.Queries, ctx context.Context, user *schema.AuthUser, factor *schema.AuthMfaFactor) (*WebAuthnUser, error) {
+	stored, err := queries.GetWebAuthnCredentialByFactor(ctx, factor.ID)
+	if err != nil {
+		return nil, ErrDatabaseJob
+	}
+	return NewWebAuthnUser(user, []*schema.AuthWebauthnCredential{stored})
+}
+
+// updateWebAuthnCredential stores the sign count of the credential after an assertion, refusing cloned authenticators
+func updateWebAuthnCredential(queries *schema.Queries, ctx context.Context, stored *schema.AuthWebauthnCredential, credential *webauthn.Credential) error {
+	if credential.Authenticator.CloneWarning {
+		return fmt.Errorf("%w: authenticator may be cloned", ErrWebAuthnFailed)
+	}
+
+	marshalled, err := json.Marshal(credential)
+	if err != nil {
+		return err
+	}
+
+	if err := queries.UpdateWebAuthnCredential(ctx, schema.UpdateWebAuthnCredentialParams{
+		ID:         stored.ID,
+		Credential: marshalled,
+	}); err != nil {
+		logrus.WithError(err).Error(ErrDatabaseJob)
+		return ErrDatabaseJob
+	}
+
+	return nil
+}
@@ -2,12 +2,14 @@ package conf
 
 import (
 	"errors"
+	"fmt"
 	"github.com/gobwas/glob"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/joho/godotenv"
 	"github.com/kelseyhightower/envconfig"
 	"github.com/lestrrat-go/jwx/v2/jwk"
 	"github.com/sirupsen/logrus"
+	"strings"
 	"surge/internal/utilities"
 )
 
@@ -16,6 +18,7 @@ type SurgeDatabaseConfigurations struct {
 }
 
 type SurgeAuthenticateConfigurations struct {
+	// CredentialsRequire* are fields required when signing up, users created by OTPCreateUser only have the email or phone
 	CredentialsRequireEmail    bool `default:"false" split_words:"true"`
 	CredentialsRequirePhone    bool `default:"false" split_words:"true"`
 	CredentialsRequireUsername bool `default:"false" split_words:"true"`
@@ -24,13 +27,31 @@ type SurgeAuthenticateConfigurations struct {
 	DisableUsernameAuth bool `default:"false" split_words:"true"`
 	DisablePhoneAuth    bool `default:"false" split_words:"true"`
 
+	// AutoLinkSameEmail links external identities to the user with the same email if both confirmed owning it
 	AutoLinkSameEmail bool `default:"true" split_words:"true"`
 	AutoConfirmEmail  bool `default:"false" split_words:"true"`
+	AutoConfirmPhone  bool `default:"false" split_words:"true"`
+
+	DisableOTPAuth bool `default:"false" split_words:"true"`
+	// OTPCreateUser creates a user without password when a magic link or code is requested for an unknown email
+	OTPCreateUser bool `default:"false" split_words:"true"`
+
+	// SoftDeleteUsers anonymizes deleted users instead of deleting them, keeping the row for auditing
+	SoftDeleteUsers bool `default:"false" split_words:"true"`
+	// PurgeDeletedUsersAfter is how many days soft deleted users are kept before being deleted, 0 keeps them forever
+	PurgeDeletedUsersAfter int `default:"0" split_words:"true"`
 }
 
 type SurgeJWTConfigurations struct {
 	ExpiresAfter int `required:"true" split_words:"true"`
 
+	// RefreshTokenReuseInterval is the grace period in seconds a rotated refresh token can still be presented,
+	// for clients that refresh concurrently. Presenting it after the interval revokes the whole token chain.
+	RefreshTokenReuseInterval int `default:"10" split_words:"true"`
+	// RefreshTokenHashKey is the key refresh tokens are hashed with before being stored, defaults to Secret.
+	// Changing it invalidates every issued refresh token.
+	RefreshTokenHashKey string `split_words:"true"`
+
 	Secret string `required:"true"`
 	Keys   JwkMap
 	KeyID  string `split_words:"true"`
@@ -49,15 +70,110 @@ type SurgeCookieConfigurations struct {
 	Duration int    `json:"duration"`
 }
 
+const (
+	MailerDriverLog  = "log"
+	MailerDriverSMTP = "smtp"
+)
+
+type SurgeSMTPConfigurations struct {
+	Host     string
+	Port     int `default:"587"`
+	User     string
+	Password string
+}
+
+type SurgeMailerConfigurations struct {
+	// Driver is either "smtp" or "log", log only writes mails to the log and is meant for development
+	Driver string `default:"log"`
+	From   string `default:"no-reply@localhost"`
+	SMTP   SurgeSMTPConfigurations
+
+	ConfirmationExpiresAfter int `default:"86400" split_words:"true"`
+	RecoveryExpiresAfter     int `default:"3600" split_words:"true"`
+	MagicLinkExpiresAfter    int `default:"3600" split_words:"true"`
+	OTPExpiresAfter          int `default:"300" split_words:"true"`
+	// OTPResendInterval is how many seconds have to pass before another magic link, code, recovery or confirmation link is mailed to the same user
+	OTPResendInterval int `default:"60" split_words:"true"`
+}
+
+const (
+	SMSDriverLog    = "log"
+	SMSDriverTwilio = "twilio"
+)
+
+type SurgeTwilioConfigurations struct {
+	AccountSID string `split_words:"true"`
+	AuthToken  string `split_words:"true"`
+	// From is the sender phone number, or a messaging service SID starting with MG
+	From string
+	// BaseURL overrides the API url, e.g. to point to a fake server
+	BaseURL string `default:"https://api.twilio.com" split_words:"true"`
+}
+
+type SurgeSMSConfigurations struct {
+	// Driver is either "twilio" or "log", log only writes messages to the log and is meant for development
+	Driver string `default:"log"`
+	Twilio SurgeTwilioConfigurations
+
+	OTPExpiresAfter int `default:"300" split_words:"true"`
+	// OTPResendInterval is how many seconds have to pass before another code is texted to the same user
+	OTPResendInterval int `default:"60" split_words:"true"`
+}
+
+type SurgeMFAConfigurations struct {
+	// Issuer is shown in authenticator apps next to the account name
+	Issuer             string `default:"Surge"`
+	MaxEnrolledFactors int    `default:"10" split_words:"true"`
+
+	ChallengeExpiresAfter int `default:"300" split_words:"true"`
+	// MaxChallengeAttempts is how many wrong codes invalidate a challenge
+	MaxChallengeAttempts int `default:"5" split_words:"true"`
+	// MaxFactorFailures is how many wrong codes in a row lock the factor for FactorLockoutDuration seconds
+	MaxFactorFailures     int `default:"10" split_words:"true"`
+	FactorLockoutDuration int `default:"900" split_words:"true"`
+	// TokenExpiresAfter is how long a user has to complete signing in with a second factor
+	TokenExpiresAfter int `default:"300" split_words:"true"`
+	// RecoveryCodeCount is how many recovery codes are generated at once
+	RecoveryCodeCount int `default:"10" split_words:"true"`
+}
+
+type SurgeWebAuthnConfigurations struct {
+	// RPID is the domain passkeys are bound to, WebAuthn is disabled if empty
+	RPID          string   `envconfig:"rp_id"`
+	RPDisplayName string   `envconfig:"rp_display_name" default:"Surge"`
+	RPOrigins     []string `envconfig:"rp_origins"`
+
+	ChallengeExpiresAfter int `default:"300" split_words:"true"`
+}
+
+// Enabled reports whether WebAuthn is configured
+func (c *SurgeWebAuthnConfigurations) Enabled() bool {
+	return c.RPID != ""
+}
+
+type SurgeAdminConfigurations struct {
+	// ServiceRoleKey is a static Bearer token granting access to the admin api, meant for trusted backends only
+	ServiceRoleKey string `split_words:"true"`
+	// Roles are values of the role claim granting access to the admin api, for JWTs signed with the configured keys
+	Roles []string `default:"service_role"`
+}
+
 type SurgeConfigurations struct {
 	Auth     SurgeAuthenticateConfigurations
 	JWT      SurgeJWTConfigurations
 	Cookie   SurgeCookieConfigurations
 	Database SurgeDatabaseConfigurations `required:"true"`
 	External SurgeExternalConfigurations
+	Mailer   SurgeMailerConfigurations
+	SMS      SurgeSMSConfigurations
+	MFA      SurgeMFAConfigurations
+	WebAuthn SurgeWebAuthnConfigurations
+	Admin    SurgeAdminConfigurations
 
 	ServiceURL string `required:"true" split_words:"true"`
 	Host       string `default:"0.0.0.0:3000"`
+	// PublicURL is the url Surge itself is reachable at, used for links sent to users
+	PublicURL string `split_words:"true"`
 
 	URIAllowListMap map[string]glob.Glob
 	URIAllowList    []string `envconfig:"surge_uri_allow_list" split_words:"true"`
@@ -137,6 +253,10 @@ func (c *SurgeConfigurations) ApplyDefaults() error {
 		}
 	}
 
+	if c.JWT.RefreshTokenHashKey == "" {
+		c.JWT.RefreshTokenHashKey = c.JWT.Secret
+	}
+
 	if c.JWT.ValidMethods == nil {
 		c.JWT.ValidMethods = []string{}
 		for _, key := range c.JWT.Keys {
@@ -145,6 +265,11 @@ func (c *SurgeConfigurations) ApplyDefaults() error {
 		}
 	}
 
+	if c.PublicURL == "" {
+		c.PublicURL = "http://" + c.Host
+	}
+	c.PublicURL = strings.TrimSuffix(c.PublicURL, "/")
+
 	if c.URIAllowList == nil {
 		c.URIAllowList = []string{}
 	}
@@ -161,8 +286,42 @@ func (c *SurgeConfigurations) ApplyDefaults() error {
 }
 
 func (c *SurgeConfigurations) Validate() error {
-	if c.Auth.AutoConfirmEmail == false {
-		return errors.New(`SURGE_AUTH_AUTO_CONFIRM_EMAIL must be set to true. email confirmation is not supported yet`)
+	switch c.Mailer.Driver {
+	case MailerDriverLog:
+	case MailerDriverSMTP:
+		if c.Mailer.SMTP.Host == "" {
+			return errors.New(`SURGE_MAILER_SMTP_HOST is required if SURGE_MAILER_DRIVER is smtp`)
+		}
+	default:
+		return fmt.Errorf(`SURGE_MAILER_DRIVER must be one of "log" or "smtp", got %q`, c.Mailer.Driver)
+	}
+
+	if c.WebAuthn.Enabled() && len(c.WebAuthn.RPOrigins) == 0 {
+		return errors.New(`SURGE_WEBAUTHN_RP_ORIGINS is required if SURGE_WEBAUTHN_RP_ID is set`)
+	}
+
+	if c.Auth.PurgeDeletedUsersAfter < 0 {
+		return errors.New(`SURGE_AUTH_PURGE_DELETED_USERS_AFTER must not be negative`)
+	}
+
+	switch c.External.ProfileSyncPolicy {
+	case ProfileSyncPolicyNever, ProfileSyncPolicyFillEmpty, ProfileSyncPolicyAlways:
+	default:
+		return fmt.Errorf(`SURGE_EXTERNAL_PROFILE_SYNC_POLICY must be one of "never", "fill_empty" or "always", got %q`, c.External.ProfileSyncPolicy)
+	}
+
+	if c.Admin.ServiceRoleKey != "" && len(c.Admin.ServiceRoleKey) < 32 {
+		return errors.New(`SURGE_ADMIN_SERVICE_ROLE_KEY must be at least 32 characters long`)
+	}
+
+	switch c.SMS.Driver {
+	case SMSDriverLog:
+	case SMSDriverTwilio:
+		if c.SMS.Twilio.AccountSID == "" || c.SMS.Twilio.AuthToken == "" || c.SMS.Twilio.From == "" {
+			return errors.New(`SURGE_SMS_TWILIO_ACCOUNT_SID, SURGE_SMS_TWILIO_AUTH_TOKEN and SURGE_SMS_TWILIO_FROM are required if SURGE_SMS_DRIVER is twilio`)
+		}
+	default:
+		return fmt.Errorf(`SURGE_SMS_DRIVER must be one of "log" or "twilio", got %q`, c.SMS.Driver)
 	}
 	return nil
 }
@@ -1,12 +1,19 @@
 package conf
 
-import "errors"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
 
 var (
 	ErrProviderDisabled = errors.New("provider is disabled")
 	ErrNoClientID       = errors.New("missing client id")
 	ErrNoClientSecret   = errors.New("missing client secret")
 	ErrNoRedirectUri    = errors.New("missing redirect uri")
+	ErrNoIssuer         = errors.New("missing issuer")
+	ErrNoAppleKey       = errors.New("missing team id, key id or private key")
 )
 
 type SurgeProviderConfiguration struct {
@@ -34,6 +41,108 @@ func (c SurgeProviderConfiguration) Validate() error {
 	return nil
 }
 
+// SurgeAppleProviderConfiguration configures Sign in with Apple, the client secret is a JWT signed with the private key
+type SurgeAppleProviderConfiguration struct {
+	SurgeProviderConfiguration
+
+	TeamID string `json:"team_id" split_words:"true"`
+	KeyID  string `json:"key_id" split_words:"true"`
+	// PrivateKey is the content of the .p8 key file downloaded from Apple
+	PrivateKey string `json:"private_key" split_words:"true"`
+}
+
+func (c SurgeAppleProviderConfiguration) Validate() error {
+	if !c.Enabled {
+		return ErrProviderDisabled
+	}
+	if len(c.ClientID) == 0 {
+		return ErrNoClientID
+	}
+	if c.RedirectURI == "" {
+		return ErrNoRedirectUri
+	}
+	if c.TeamID == "" || c.KeyID == "" || c.PrivateKey == "" {
+		return ErrNoAppleKey
+	}
+	return nil
+}
+
+// SurgeMicrosoftProviderConfiguration configures the Microsoft identity platform, URL overrides the login host for national clouds
+type SurgeMicrosoftProviderConfiguration struct {
+	SurgeProviderConfiguration
+
+	// Tenant is "common" for any account, "organizations" for work and school accounts, "consumers" for personal
+	// accounts, or a tenant id or domain. A domain is resolved to the id of its tenant when the provider is created.
+	Tenant string `json:"tenant" default:"common"`
+	// AllowedTenants are ids of tenants allowed to sign in, any tenant is allowed if empty
+	AllowedTenants []string `json:"allowed_tenants" split_words:"true"`
+}
+
+const (
+	// ProfileSyncPolicyNever keeps the profile of the user as it was when the user was created
+	ProfileSyncPolicyNever = "never"
+	// ProfileSyncPolicyFillEmpty copies profile fields from the provider only if the user has none
+	ProfileSyncPolicyFillEmpty = "fill_empty"
+	// ProfileSyncPolicyAlways overwrites profile fields with the ones of the provider on every sign in
+	ProfileSyncPolicyAlways = "always"
+)
+
 type SurgeExternalConfigurations struct {
 	Google SurgeProviderConfiguration `json:"google"`
+	// Github signs in with github.com, or GitHub Enterprise if URL is set
+	Github SurgeProviderConfiguration `json:"github"`
+	// Apple signs in with Apple, the first client id is the services id used on the web
+	Apple SurgeAppleProviderConfiguration `json:"apple"`
+	// Microsoft signs in with Microsoft Entra ID and personal Microsoft accounts
+	Microsoft SurgeMicrosoftProviderConfiguration `json:"microsoft"`
+	// Gitlab signs in with gitlab.com, or a self-hosted instance if URL is set
+	Gitlab  SurgeProviderConfiguration `json:"gitlab"`
+	Discord SurgeProviderConfiguration `json:"discord"`
+	Twitch  SurgeProviderConfiguration `json:"twitch"`
+	Slack   SurgeProviderConfiguration `json:"slack"`
+
+	// OIDC declares any number of OpenID Connect providers, e.g. Keycloak, Okta or Auth0, as a JSON object by name
+	OIDC OIDCProviderMap `json:"oidc"`
+
+	// ProfileSyncPolicy is how profile fields of the user are updated when signing in with an existing identity
+	ProfileSyncPolicy string `json:"profile_sync_policy" default:"fill_empty" split_words:"true"`
+}
+
+// SurgeOIDCProviderConfiguration configures an OpenID Connect provider discovered from its issuer
+type SurgeOIDCProviderConfiguration struct {
+	SurgeProviderConfiguration
+
+	Issuer string `json:"issuer"`
+	// Scopes are requested in addition to openid, defaults to email and profile
+	Scopes []string `json:"scopes"`
+	// ClaimMapping maps standard claims such as email or preferred_username to the claims the issuer uses instead
+	ClaimMapping map[string]string `json:"claim_mapping"`
+}
+
+// OIDCProviderMap is OpenID Connect providers by their name, names of built-in providers take precedence
+type OIDCProviderMap map[string]SurgeOIDCProviderConfiguration
+
+// Decode implements the Decoder interface, the value is a JSON object of providers by their name
+func (m *OIDCProviderMap) Decode(value string) error {
+	data := make(map[string]json.RawMessage)
+	if err := json.Unmarshal([]byte(value), &data); err != nil {
+		return err
+	}
+
+	providers := OIDCProviderMap{}
+	for name, raw := range data {
+		config := SurgeOIDCProviderConfiguration{
+			SurgeProviderConfiguration: SurgeProviderConfiguration{Enabled: true},
+		}
+		if err := json.Unmarshal(raw, &config); err != nil {
+			return fmt.Errorf("oidc provider %q: %w", name, err)
+		}
+		if config.Issuer == "" {
+			return fmt.Errorf("oidc provider %q: %w", name, ErrNoIssuer)
+		}
+
+		providers[strings.ToLower(name)] = config
+	}
+	*m = providers
+	return nil
 }
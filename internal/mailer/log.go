@@ -0,0 +1,19 @@
+package mailer
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+)
+
+// logMailer writes mails to the log instead of sending them, for development only
+type logMailer struct{}
+
+func (m *logMailer) Mail(ctx context.Context, message Message) error {
+	logrus.WithContext(ctx).
+		WithField("component", "mailer").
+		WithField("to", message.To).
+		WithField("subject", message.Subject).
+		Infof("Mail:\n%s", message.Body)
+
+	return nil
+}
@@ -0,0 +1,28 @@
+package mailer
+
+import (
+	"context"
+	"surge/internal/conf"
+)
+
+// Message is a plain text mail to be sent to a single recipient
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers mails to users
+type Mailer interface {
+	Mail(ctx context.Context, message Message) error
+}
+
+// NewMailer returns the Mailer for the configured driver
+func NewMailer(config *conf.SurgeMailerConfigurations) Mailer {
+	switch config.Driver {
+	case conf.MailerDriverSMTP:
+		return &smtpMailer{config: config}
+	default:
+		return &logMailer{}
+	}
+}
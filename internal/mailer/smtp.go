@@ -0,0 +1,50 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"surge/internal/conf"
+	"time"
+)
+
+// smtpMailer sends mails through an SMTP server, e.g. MailHog (localhost:1025) for local development
+type smtpMailer struct {
+	config *conf.SurgeMailerConfigurations
+}
+
+func (m *smtpMailer) Mail(ctx context.Context, message Message) error {
+	config := m.config.SMTP
+	addr := net.JoinHostPort(config.Host, strconv.Itoa(config.Port))
+
+	var auth smtp.Auth
+	if config.User != "" {
+		auth = smtp.PlainAuth("", config.User, config.Password, config.Host)
+	}
+
+	return smtp.SendMail(addr, auth, m.config.From, []string{message.To}, m.compose(message))
+}
+
+func (m *smtpMailer) compose(message Message) []byte {
+	var builder strings.Builder
+
+	headers := [][2]string{
+		{"From", m.config.From},
+		{"To", message.To},
+		{"Subject", message.Subject},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "text/plain; charset=UTF-8"},
+	}
+	for _, header := range headers {
+		builder.WriteString(fmt.Sprintf("%s: %s\r\n", header[0], header[1]))
+	}
+
+	builder.WriteString("\r\n")
+	builder.WriteString(strings.ReplaceAll(message.Body, "\n", "\r\n"))
+
+	return []byte(builder.String())
+}
@@ -0,0 +1,63 @@
+package mailer
+
+import "fmt"
+
+// NewConfirmationMessage creates a mail asking the user to confirm the email address through the link
+func NewConfirmationMessage(to string, link string) Message {
+	return Message{
+		To:      to,
+		Subject: "Confirm your email",
+		Body: fmt.Sprintf(
+			"Follow this link to confirm your email address:\n\n%s\n\nIf you didn't request this, you can safely ignore this email.\n",
+			link,
+		),
+	}
+}
+
+// NewEmailChangeMessage creates a mail asking the user to confirm the new email address through the link
+func NewEmailChangeMessage(to string, link string) Message {
+	return Message{
+		To:      to,
+		Subject: "Confirm your new email",
+		Body: fmt.Sprintf(
+			"Follow this link to change the email address of your account to this one:\n\n%s\n\nIf you didn't request this, you can safely ignore this email.\n",
+			link,
+		),
+	}
+}
+
+// NewRecoveryMessage creates a mail with the link to reset password of the user
+func NewRecoveryMessage(to string, link string) Message {
+	return Message{
+		To:      to,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Follow this link to reset your password:\n\n%s\n\nIf you didn't request this, you can safely ignore this email.\n",
+			link,
+		),
+	}
+}
+
+// NewMagicLinkMessage creates a mail with the link to sign in without password
+func NewMagicLinkMessage(to string, link string) Message {
+	return Message{
+		To:      to,
+		Subject: "Your sign in link",
+		Body: fmt.Sprintf(
+			"Follow this link to sign in:\n\n%s\n\nIf you didn't request this, you can safely ignore this email.\n",
+			link,
+		),
+	}
+}
+
+// NewOTPMessage creates a mail with the code to sign in without password
+func NewOTPMessage(to string, code string) Message {
+	return Message{
+		To:      to,
+		Subject: "Your sign in code",
+		Body: fmt.Sprintf(
+			"Enter this code to sign in:\n\n%s\n\nIf you didn't request this, you can safely ignore this email.\n",
+			code,
+		),
+	}
+}
@@ -209,3 +209,25 @@ func (q *Queries) UpdateIdentityLastSignIn(ctx context.Context, id uuid.UUID) (*
 	)
 	return &i, err
 }
+
+const deleteIdentitiesOfUser = `-- name: DeleteIdentitiesOfUser :exec
+DELETE
+from auth.identities
+WHERE user_id = $1
+`
+
+func (q *Queries) DeleteIdentitiesOfUser(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteIdentitiesOfUser, userID)
+	return err
+}
+
+const deleteIdentity = `-- name: DeleteIdentity :exec
+DELETE
+from auth.identities
+WHERE id = $1
+`
+
+func (q *Queries) DeleteIdentity(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteIdentity, id)
+	return err
+}
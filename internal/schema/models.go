@@ -10,8 +10,18 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/sqlc-dev/pqtype"
 )
 
+type AuthAuditLogEntry struct {
+	ID        uuid.UUID
+	UserID    uuid.NullUUID
+	Action    string
+	IpAddress sql.NullString
+	Payload   json.RawMessage
+	CreatedAt time.Time
+}
+
 type AuthIdentity struct {
 	ID           uuid.UUID
 	UserID       uuid.UUID
@@ -24,6 +34,49 @@ type AuthIdentity struct {
 	LastSignIn   sql.NullTime
 }
 
+type AuthMfaChallenge struct {
+	ID                  uuid.UUID
+	FactorID            uuid.UUID
+	IpAddress           sql.NullString
+	CreatedAt           time.Time
+	VerifiedAt          sql.NullTime
+	WebauthnSessionData pqtype.NullRawMessage
+	FailedAttempts      int32
+}
+
+type AuthMfaFactor struct {
+	ID             uuid.UUID
+	UserID         uuid.UUID
+	FactorType     string
+	Status         string
+	FriendlyName   sql.NullString
+	Secret         sql.NullString
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	FailedAttempts int32
+	LockedUntil    sql.NullTime
+	LastTotpStep   sql.NullInt64
+}
+
+type AuthMfaRecoveryCode struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	CodeHash  string
+	CreatedAt time.Time
+	UsedAt    sql.NullTime
+}
+
+type AuthOneTimeToken struct {
+	ID             uuid.UUID
+	UserID         uuid.UUID
+	TokenType      string
+	TokenHash      string
+	RelatesTo      string
+	CreatedAt      time.Time
+	ExpiresAt      time.Time
+	FailedAttempts int32
+}
+
 type AuthRefreshToken struct {
 	ID        int64
 	UserID    uuid.NullUUID
@@ -31,6 +84,21 @@ type AuthRefreshToken struct {
 	Revoked   bool
 	CreatedAt time.Time
 	UpdatedAt time.Time
+	SessionID uuid.NullUUID
+	ParentID  sql.NullInt64
+	Hashed    bool
+}
+
+type AuthSession struct {
+	ID          uuid.UUID
+	UserID      uuid.UUID
+	UserAgent   sql.NullString
+	IpAddress   sql.NullString
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	RefreshedAt sql.NullTime
+	Aal         string
+	Amr         json.RawMessage
 }
 
 type AuthUser struct {
@@ -47,4 +115,26 @@ type AuthUser struct {
 	CreatedAt         time.Time
 	UpdatedAt         time.Time
 	LastSignIn        sql.NullTime
+	EmailConfirmedAt  sql.NullTime
+	PhoneConfirmedAt  sql.NullTime
+	BannedUntil       sql.NullTime
+	DeletedAt         sql.NullTime
+	EmailChange       sql.NullString
+}
+
+type AuthWebauthnChallenge struct {
+	ID          uuid.UUID
+	SessionData json.RawMessage
+	CreatedAt   time.Time
+}
+
+type AuthWebauthnCredential struct {
+	ID           uuid.UUID
+	FactorID     uuid.UUID
+	UserID       uuid.UUID
+	CredentialID []byte
+	Credential   json.RawMessage
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	LastUsedAt   sql.NullTime
 }
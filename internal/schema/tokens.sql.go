@@ -13,19 +13,52 @@ import (
 )
 
 const createRefreshToken = `-- name: CreateRefreshToken :one
-insert into auth.refresh_tokens(user_id, token, revoked, created_at, updated_at)
-values ($1, $2, $3, now(), now())
-returning id, user_id, token, revoked, created_at, updated_at
+insert into auth.refresh_tokens(user_id, session_id, parent_id, token, hashed, revoked, created_at, updated_at)
+values ($1, $2, $3, $4, true, $5, now(), now())
+returning id, user_id, token, revoked, created_at, updated_at, session_id, parent_id, hashed
 `
 
 type CreateRefreshTokenParams struct {
-	UserID  uuid.NullUUID
-	Token   sql.NullString
-	Revoked bool
+	UserID    uuid.NullUUID
+	SessionID uuid.NullUUID
+	ParentID  sql.NullInt64
+	Token     sql.NullString
+	Revoked   bool
 }
 
 func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshTokenParams) (*AuthRefreshToken, error) {
-	row := q.db.QueryRowContext(ctx, createRefreshToken, arg.UserID, arg.Token, arg.Revoked)
+	row := q.db.QueryRowContext(ctx, createRefreshToken,
+		arg.UserID,
+		arg.SessionID,
+		arg.ParentID,
+		arg.Token,
+		arg.Revoked,
+	)
+	var i AuthRefreshToken
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Token,
+		&i.Revoked,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.SessionID,
+		&i.ParentID,
+		&i.Hashed,
+	)
+	return &i, err
+}
+
+const getLatestRefreshTokenChild = `-- name: GetLatestRefreshTokenChild :one
+select id, user_id, token, revoked, created_at, updated_at, session_id, parent_id, hashed
+from auth.refresh_tokens
+where parent_id = $1
+order by id desc
+limit 1
+`
+
+func (q *Queries) GetLatestRefreshTokenChild(ctx context.Context, parentID sql.NullInt64) (*AuthRefreshToken, error) {
+	row := q.db.QueryRowContext(ctx, getLatestRefreshTokenChild, parentID)
 	var i AuthRefreshToken
 	err := row.Scan(
 		&i.ID,
@@ -34,18 +67,28 @@ func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshToken
 		&i.Revoked,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.SessionID,
+		&i.ParentID,
+		&i.Hashed,
 	)
 	return &i, err
 }
 
 const getRefreshToken = `-- name: GetRefreshToken :one
-select id, user_id, token, revoked, created_at, updated_at
+select id, user_id, token, revoked, created_at, updated_at, session_id, parent_id, hashed
 from auth.refresh_tokens
-where token = $1::varchar and revoked = false
+where (hashed = true and token = $1::varchar)
+   or (hashed = false and token = $2::varchar and created_at > now() - interval '30 days')
+    for update
 `
 
-func (q *Queries) GetRefreshToken(ctx context.Context, token string) (*AuthRefreshToken, error) {
-	row := q.db.QueryRowContext(ctx, getRefreshToken, token)
+type GetRefreshTokenParams struct {
+	TokenHash string
+	Token     string
+}
+
+func (q *Queries) GetRefreshToken(ctx context.Context, arg GetRefreshTokenParams) (*AuthRefreshToken, error) {
+	row := q.db.QueryRowContext(ctx, getRefreshToken, arg.TokenHash, arg.Token)
 	var i AuthRefreshToken
 	err := row.Scan(
 		&i.ID,
@@ -54,12 +97,32 @@ func (q *Queries) GetRefreshToken(ctx context.Context, token string) (*AuthRefre
 		&i.Revoked,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.SessionID,
+		&i.ParentID,
+		&i.Hashed,
 	)
 	return &i, err
 }
 
+const hashRefreshToken = `-- name: HashRefreshToken :exec
+update auth.refresh_tokens
+set token  = $2,
+    hashed = true
+where id = $1
+`
+
+type HashRefreshTokenParams struct {
+	ID    int64
+	Token sql.NullString
+}
+
+func (q *Queries) HashRefreshToken(ctx context.Context, arg HashRefreshTokenParams) error {
+	_, err := q.db.ExecContext(ctx, hashRefreshToken, arg.ID, arg.Token)
+	return err
+}
+
 const listRefreshTokenByUser = `-- name: ListRefreshTokenByUser :many
-select id, user_id, token, revoked, created_at, updated_at
+select id, user_id, token, revoked, created_at, updated_at, session_id, parent_id, hashed
 from auth.refresh_tokens
 where user_id = $1
 `
@@ -80,6 +143,9 @@ func (q *Queries) ListRefreshTokenByUser(ctx context.Context, userID uuid.NullUU
 			&i.Revoked,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.SessionID,
+			&i.ParentID,
+			&i.Hashed,
 		); err != nil {
 			return nil, err
 		}
@@ -96,7 +162,8 @@ func (q *Queries) ListRefreshTokenByUser(ctx context.Context, userID uuid.NullUU
 
 const revokeRefreshToken = `-- name: RevokeRefreshToken :exec
 update auth.refresh_tokens
-set revoked = true
+set revoked    = true,
+    updated_at = now()
 where id = $1
 `
 
@@ -105,9 +172,29 @@ func (q *Queries) RevokeRefreshToken(ctx context.Context, id int64) error {
 	return err
 }
 
+const revokeRefreshTokenDescendants = `-- name: RevokeRefreshTokenDescendants :exec
+with recursive descendants as (select id
+                               from auth.refresh_tokens
+                               where parent_id = $1
+                               union all
+                               select child.id
+                               from auth.refresh_tokens child
+                                        join descendants on child.parent_id = descendants.id)
+update auth.refresh_tokens
+set revoked    = true,
+    updated_at = now()
+where id in (select id from descendants)
+`
+
+func (q *Queries) RevokeRefreshTokenDescendants(ctx context.Context, parentID sql.NullInt64) error {
+	_, err := q.db.ExecContext(ctx, revokeRefreshTokenDescendants, parentID)
+	return err
+}
+
 const revokeRefreshTokensOfUser = `-- name: RevokeRefreshTokensOfUser :exec
 update auth.refresh_tokens
-set revoked = true
+set revoked    = true,
+    updated_at = now()
 where user_id = $1
 `
 
@@ -115,3 +202,27 @@ func (q *Queries) RevokeRefreshTokensOfUser(ctx context.Context, userID uuid.Nul
 	_, err := q.db.ExecContext(ctx, revokeRefreshTokensOfUser, userID)
 	return err
 }
+
+const revokeSessionlessRefreshTokensOfUser = `-- name: RevokeSessionlessRefreshTokensOfUser :exec
+update auth.refresh_tokens
+set revoked    = true,
+    updated_at = now()
+where user_id = $1
+  and session_id is null
+`
+
+func (q *Queries) RevokeSessionlessRefreshTokensOfUser(ctx context.Context, userID uuid.NullUUID) error {
+	_, err := q.db.ExecContext(ctx, revokeSessionlessRefreshTokensOfUser, userID)
+	return err
+}
+
+const deleteRefreshTokensOfUser = `-- name: DeleteRefreshTokensOfUser :exec
+delete
+from auth.refresh_tokens
+where user_id = $1
+`
+
+func (q *Queries) DeleteRefreshTokensOfUser(ctx context.Context, userID uuid.NullUUID) error {
+	_, err := q.db.ExecContext(ctx, deleteRefreshTokensOfUser, userID)
+	return err
+}
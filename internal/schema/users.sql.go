@@ -9,6 +9,7 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/sqlc-dev/pqtype"
@@ -29,7 +30,7 @@ values ($1,
         $9,
         now(),
         now())
-returning id, phone, email, username, encrypted_password, meta_avatar, meta_first_name, meta_last_name, meta_birthdate, meta_extra, created_at, updated_at, last_sign_in
+returning id, phone, email, username, encrypted_password, meta_avatar, meta_first_name, meta_last_name, meta_birthdate, meta_extra, created_at, updated_at, last_sign_in, email_confirmed_at, phone_confirmed_at, banned_until, deleted_at, email_change
 `
 
 type CreateUserParams struct {
@@ -71,12 +72,17 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (*AuthUs
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.LastSignIn,
+		&i.EmailConfirmedAt,
+		&i.PhoneConfirmedAt,
+		&i.BannedUntil,
+		&i.DeletedAt,
+		&i.EmailChange,
 	)
 	return &i, err
 }
 
 const getUser = `-- name: GetUser :one
-select id, phone, email, username, encrypted_password, meta_avatar, meta_first_name, meta_last_name, meta_birthdate, meta_extra, created_at, updated_at, last_sign_in
+select id, phone, email, username, encrypted_password, meta_avatar, meta_first_name, meta_last_name, meta_birthdate, meta_extra, created_at, updated_at, last_sign_in, email_confirmed_at, phone_confirmed_at, banned_until, deleted_at, email_change
 from auth.users
 where id = $1
 `
@@ -98,12 +104,17 @@ func (q *Queries) GetUser(ctx context.Context, id uuid.UUID) (*AuthUser, error)
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.LastSignIn,
+		&i.EmailConfirmedAt,
+		&i.PhoneConfirmedAt,
+		&i.BannedUntil,
+		&i.DeletedAt,
+		&i.EmailChange,
 	)
 	return &i, err
 }
 
 const getUserByEmail = `-- name: GetUserByEmail :one
-select id, phone, email, username, encrypted_password, meta_avatar, meta_first_name, meta_last_name, meta_birthdate, meta_extra, created_at, updated_at, last_sign_in
+select id, phone, email, username, encrypted_password, meta_avatar, meta_first_name, meta_last_name, meta_birthdate, meta_extra, created_at, updated_at, last_sign_in, email_confirmed_at, phone_confirmed_at, banned_until, deleted_at, email_change
 from auth.users
 where email = $1::varchar
 `
@@ -125,18 +136,23 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (*AuthUser,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.LastSignIn,
+		&i.EmailConfirmedAt,
+		&i.PhoneConfirmedAt,
+		&i.BannedUntil,
+		&i.DeletedAt,
+		&i.EmailChange,
 	)
 	return &i, err
 }
 
-const getUserByRefreshToken = `-- name: GetUserByRefreshToken :one
-select id, phone, email, username, encrypted_password, meta_avatar, meta_first_name, meta_last_name, meta_birthdate, meta_extra, created_at, updated_at, last_sign_in
+const getUserByPhone = `-- name: GetUserByPhone :one
+select id, phone, email, username, encrypted_password, meta_avatar, meta_first_name, meta_last_name, meta_birthdate, meta_extra, created_at, updated_at, last_sign_in, email_confirmed_at, phone_confirmed_at, banned_until, deleted_at, email_change
 from auth.users
-where (select id, user_id, token, revoked, created_at, updated_at from auth.refresh_tokens where token = $1::varchar)
+where phone = $1::text
 `
 
-func (q *Queries) GetUserByRefreshToken(ctx context.Context, token string) (*AuthUser, error) {
-	row := q.db.QueryRowContext(ctx, getUserByRefreshToken, token)
+func (q *Queries) GetUserByPhone(ctx context.Context, phone string) (*AuthUser, error) {
+	row := q.db.QueryRowContext(ctx, getUserByPhone, phone)
 	var i AuthUser
 	err := row.Scan(
 		&i.ID,
@@ -152,12 +168,17 @@ func (q *Queries) GetUserByRefreshToken(ctx context.Context, token string) (*Aut
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.LastSignIn,
+		&i.EmailConfirmedAt,
+		&i.PhoneConfirmedAt,
+		&i.BannedUntil,
+		&i.DeletedAt,
+		&i.EmailChange,
 	)
 	return &i, err
 }
 
 const getUserByUsername = `-- name: GetUserByUsername :one
-select id, phone, email, username, encrypted_password, meta_avatar, meta_first_name, meta_last_name, meta_birthdate, meta_extra, created_at, updated_at, last_sign_in
+select id, phone, email, username, encrypted_password, meta_avatar, meta_first_name, meta_last_name, meta_birthdate, meta_extra, created_at, updated_at, last_sign_in, email_confirmed_at, phone_confirmed_at, banned_until, deleted_at, email_change
 from auth.users
 where username = $1::varchar
 `
@@ -179,6 +200,11 @@ func (q *Queries) GetUserByUsername(ctx context.Context, username string) (*Auth
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.LastSignIn,
+		&i.EmailConfirmedAt,
+		&i.PhoneConfirmedAt,
+		&i.BannedUntil,
+		&i.DeletedAt,
+		&i.EmailChange,
 	)
 	return &i, err
 }
@@ -187,19 +213,21 @@ const updateUser = `-- name: UpdateUser :one
 update auth.users
 set email              = coalesce($2, email),
     username           = coalesce($3, username),
-    encrypted_password = coalesce($4, encrypted_password),
+    phone              = coalesce($4, phone),
+    encrypted_password = coalesce($5, encrypted_password),
 
-    created_at         = coalesce($5, created_at),
+    created_at         = coalesce($6, created_at),
     updated_at         = now(),
-    last_sign_in       = coalesce($6, last_sign_in)
+    last_sign_in       = coalesce($7, last_sign_in)
 where id = $1
-returning id, phone, email, username, encrypted_password, meta_avatar, meta_first_name, meta_last_name, meta_birthdate, meta_extra, created_at, updated_at, last_sign_in
+returning id, phone, email, username, encrypted_password, meta_avatar, meta_first_name, meta_last_name, meta_birthdate, meta_extra, created_at, updated_at, last_sign_in, email_confirmed_at, phone_confirmed_at, banned_until, deleted_at, email_change
 `
 
 type UpdateUserParams struct {
 	ID                uuid.UUID
 	Email             sql.NullString
 	Username          sql.NullString
+	Phone             interface{}
 	EncryptedPassword sql.NullString
 	CreatedAt         sql.NullTime
 	LastSignIn        sql.NullTime
@@ -210,6 +238,7 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (*AuthUs
 		arg.ID,
 		arg.Email,
 		arg.Username,
+		arg.Phone,
 		arg.EncryptedPassword,
 		arg.CreatedAt,
 		arg.LastSignIn,
@@ -229,6 +258,11 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (*AuthUs
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.LastSignIn,
+		&i.EmailConfirmedAt,
+		&i.PhoneConfirmedAt,
+		&i.BannedUntil,
+		&i.DeletedAt,
+		&i.EmailChange,
 	)
 	return &i, err
 }
@@ -238,7 +272,7 @@ update auth.users
 set updated_at   = now(),
     last_sign_in = now()
 where id = $1
-returning id, phone, email, username, encrypted_password, meta_avatar, meta_first_name, meta_last_name, meta_birthdate, meta_extra, created_at, updated_at, last_sign_in
+returning id, phone, email, username, encrypted_password, meta_avatar, meta_first_name, meta_last_name, meta_birthdate, meta_extra, created_at, updated_at, last_sign_in, email_confirmed_at, phone_confirmed_at, banned_until, deleted_at, email_change
 `
 
 func (q *Queries) UpdateUserLastSignIn(ctx context.Context, id uuid.UUID) (*AuthUser, error) {
@@ -258,6 +292,11 @@ func (q *Queries) UpdateUserLastSignIn(ctx context.Context, id uuid.UUID) (*Auth
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.LastSignIn,
+		&i.EmailConfirmedAt,
+		&i.PhoneConfirmedAt,
+		&i.BannedUntil,
+		&i.DeletedAt,
+		&i.EmailChange,
 	)
 	return &i, err
 }
@@ -266,11 +305,12 @@ const updateUserMetadata = `-- name: UpdateUserMetadata :one
 update auth.users
 set meta_avatar     = coalesce($2, meta_avatar),
     meta_first_name = coalesce($3, meta_first_name),
-    meta_last_name  = coalesce($4, meta_first_name),
-    meta_birthdate  = coalesce($5, meta_first_name),
-    meta_extra      = coalesce($6, meta_extra)
+    meta_last_name  = coalesce($4, meta_last_name),
+    meta_birthdate  = coalesce($5, meta_birthdate),
+    meta_extra      = coalesce($6, meta_extra),
+    updated_at      = now()
 where id = $1
-returning id, phone, email, username, encrypted_password, meta_avatar, meta_first_name, meta_last_name, meta_birthdate, meta_extra, created_at, updated_at, last_sign_in
+returning id, phone, email, username, encrypted_password, meta_avatar, meta_first_name, meta_last_name, meta_birthdate, meta_extra, created_at, updated_at, last_sign_in, email_confirmed_at, phone_confirmed_at, banned_until, deleted_at, email_change
 `
 
 type UpdateUserMetadataParams struct {
@@ -306,6 +346,351 @@ func (q *Queries) UpdateUserMetadata(ctx context.Context, arg UpdateUserMetadata
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.LastSignIn,
+		&i.EmailConfirmedAt,
+		&i.PhoneConfirmedAt,
+		&i.BannedUntil,
+		&i.DeletedAt,
+		&i.EmailChange,
+	)
+	return &i, err
+}
+
+const updateUserEmailConfirmedAt = `-- name: UpdateUserEmailConfirmedAt :one
+update auth.users
+set email_confirmed_at = $2,
+    updated_at         = now()
+where id = $1
+returning id, phone, email, username, encrypted_password, meta_avatar, meta_first_name, meta_last_name, meta_birthdate, meta_extra, created_at, updated_at, last_sign_in, email_confirmed_at, phone_confirmed_at, banned_until, deleted_at, email_change
+`
+
+type UpdateUserEmailConfirmedAtParams struct {
+	ID               uuid.UUID
+	EmailConfirmedAt sql.NullTime
+}
+
+func (q *Queries) UpdateUserEmailConfirmedAt(ctx context.Context, arg UpdateUserEmailConfirmedAtParams) (*AuthUser, error) {
+	row := q.db.QueryRowContext(ctx, updateUserEmailConfirmedAt, arg.ID, arg.EmailConfirmedAt)
+	var i AuthUser
+	err := row.Scan(
+		&i.ID,
+		&i.Phone,
+		&i.Email,
+		&i.Username,
+		&i.EncryptedPassword,
+		&i.MetaAvatar,
+		&i.MetaFirstName,
+		&i.MetaLastName,
+		&i.MetaBirthdate,
+		&i.MetaExtra,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.LastSignIn,
+		&i.EmailConfirmedAt,
+		&i.PhoneConfirmedAt,
+		&i.BannedUntil,
+		&i.DeletedAt,
+		&i.EmailChange,
+	)
+	return &i, err
+}
+
+const updateUserPhoneConfirmedAt = `-- name: UpdateUserPhoneConfirmedAt :one
+update auth.users
+set phone_confirmed_at = $2,
+    updated_at         = now()
+where id = $1
+returning id, phone, email, username, encrypted_password, meta_avatar, meta_first_name, meta_last_name, meta_birthdate, meta_extra, created_at, updated_at, last_sign_in, email_confirmed_at, phone_confirmed_at, banned_until, deleted_at, email_change
+`
+
+type UpdateUserPhoneConfirmedAtParams struct {
+	ID               uuid.UUID
+	PhoneConfirmedAt sql.NullTime
+}
+
+func (q *Queries) UpdateUserPhoneConfirmedAt(ctx context.Context, arg UpdateUserPhoneConfirmedAtParams) (*AuthUser, error) {
+	row := q.db.QueryRowContext(ctx, updateUserPhoneConfirmedAt, arg.ID, arg.PhoneConfirmedAt)
+	var i AuthUser
+	err := row.Scan(
+		&i.ID,
+		&i.Phone,
+		&i.Email,
+		&i.Username,
+		&i.EncryptedPassword,
+		&i.MetaAvatar,
+		&i.MetaFirstName,
+		&i.MetaLastName,
+		&i.MetaBirthdate,
+		&i.MetaExtra,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.LastSignIn,
+		&i.EmailConfirmedAt,
+		&i.PhoneConfirmedAt,
+		&i.BannedUntil,
+		&i.DeletedAt,
+		&i.EmailChange,
+	)
+	return &i, err
+}
+
+const listUsers = `-- name: ListUsers :many
+select id, phone, email, username, encrypted_password, meta_avatar, meta_first_name, meta_last_name, meta_birthdate, meta_extra, created_at, updated_at, last_sign_in, email_confirmed_at, phone_confirmed_at, banned_until, deleted_at, email_change
+from auth.users
+where $1::text = ''
+   or email ilike '%' || $1::text || '%' escape '\'
+   or username ilike '%' || $1::text || '%' escape '\'
+   or phone ilike '%' || $1::text || '%' escape '\'
+order by created_at desc, id
+limit $2 offset $3
+`
+
+type ListUsersParams struct {
+	Query  string
+	Limit  int32
+	Offset int32
+}
+
+func (q *Queries) ListUsers(ctx context.Context, arg ListUsersParams) ([]*AuthUser, error) {
+	rows, err := q.db.QueryContext(ctx, listUsers, arg.Query, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*AuthUser
+	for rows.Next() {
+		var i AuthUser
+		if err := rows.Scan(
+			&i.ID,
+			&i.Phone,
+			&i.Email,
+			&i.Username,
+			&i.EncryptedPassword,
+			&i.MetaAvatar,
+			&i.MetaFirstName,
+			&i.MetaLastName,
+			&i.MetaBirthdate,
+			&i.MetaExtra,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.LastSignIn,
+			&i.EmailConfirmedAt,
+			&i.PhoneConfirmedAt,
+			&i.BannedUntil,
+			&i.DeletedAt,
+			&i.EmailChange,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const countUsers = `-- name: CountUsers :one
+select count(*)
+from auth.users
+where $1::text = ''
+   or email ilike '%' || $1::text || '%' escape '\'
+   or username ilike '%' || $1::text || '%' escape '\'
+   or phone ilike '%' || $1::text || '%' escape '\'
+`
+
+func (q *Queries) CountUsers(ctx context.Context, query string) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countUsers, query)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const updateUserBannedUntil = `-- name: UpdateUserBannedUntil :one
+update auth.users
+set banned_until = $2,
+    updated_at   = now()
+where id = $1
+returning id, phone, email, username, encrypted_password, meta_avatar, meta_first_name, meta_last_name, meta_birthdate, meta_extra, created_at, updated_at, last_sign_in, email_confirmed_at, phone_confirmed_at, banned_until, deleted_at, email_change
+`
+
+type UpdateUserBannedUntilParams struct {
+	ID          uuid.UUID
+	BannedUntil sql.NullTime
+}
+
+func (q *Queries) UpdateUserBannedUntil(ctx context.Context, arg UpdateUserBannedUntilParams) (*AuthUser, error) {
+	row := q.db.QueryRowContext(ctx, updateUserBannedUntil, arg.ID, arg.BannedUntil)
+	var i AuthUser
+	err := row.Scan(
+		&i.ID,
+		&i.Phone,
+		&i.Email,
+		&i.Username,
+		&i.EncryptedPassword,
+		&i.MetaAvatar,
+		&i.MetaFirstName,
+		&i.MetaLastName,
+		&i.MetaBirthdate,
+		&i.MetaExtra,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.LastSignIn,
+		&i.EmailConfirmedAt,
+		&i.PhoneConfirmedAt,
+		&i.BannedUntil,
+		&i.DeletedAt,
+		&i.EmailChange,
+	)
+	return &i, err
+}
+
+const deleteUser = `-- name: DeleteUser :exec
+delete
+from auth.users
+where id = $1
+`
+
+func (q *Queries) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteUser, id)
+	return err
+}
+
+const softDeleteUser = `-- name: SoftDeleteUser :one
+update auth.users
+set phone              = null,
+    email              = null,
+    email_change       = null,
+    username           = null,
+    encrypted_password = null,
+    meta_avatar        = null,
+    meta_first_name    = null,
+    meta_last_name     = null,
+    meta_birthdate     = null,
+    meta_extra         = '{}',
+    email_confirmed_at = null,
+    phone_confirmed_at = null,
+    banned_until       = null,
+    updated_at         = now(),
+    deleted_at         = now()
+where id = $1
+returning id, phone, email, username, encrypted_password, meta_avatar, meta_first_name, meta_last_name, meta_birthdate, meta_extra, created_at, updated_at, last_sign_in, email_confirmed_at, phone_confirmed_at, banned_until, deleted_at, email_change
+`
+
+func (q *Queries) SoftDeleteUser(ctx context.Context, id uuid.UUID) (*AuthUser, error) {
+	row := q.db.QueryRowContext(ctx, softDeleteUser, id)
+	var i AuthUser
+	err := row.Scan(
+		&i.ID,
+		&i.Phone,
+		&i.Email,
+		&i.Username,
+		&i.EncryptedPassword,
+		&i.MetaAvatar,
+		&i.MetaFirstName,
+		&i.MetaLastName,
+		&i.MetaBirthdate,
+		&i.MetaExtra,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.LastSignIn,
+		&i.EmailConfirmedAt,
+		&i.PhoneConfirmedAt,
+		&i.BannedUntil,
+		&i.DeletedAt,
+		&i.EmailChange,
+	)
+	return &i, err
+}
+
+const purgeDeletedUsers = `-- name: PurgeDeletedUsers :execrows
+delete
+from auth.users
+where deleted_at < $1::timestamptz
+`
+
+func (q *Queries) PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeDeletedUsers, deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateUserEmailChange = `-- name: UpdateUserEmailChange :one
+update auth.users
+set email_change = $2,
+    updated_at   = now()
+where id = $1
+returning id, phone, email, username, encrypted_password, meta_avatar, meta_first_name, meta_last_name, meta_birthdate, meta_extra, created_at, updated_at, last_sign_in, email_confirmed_at, phone_confirmed_at, banned_until, deleted_at, email_change
+`
+
+type UpdateUserEmailChangeParams struct {
+	ID          uuid.UUID
+	EmailChange sql.NullString
+}
+
+func (q *Queries) UpdateUserEmailChange(ctx context.Context, arg UpdateUserEmailChangeParams) (*AuthUser, error) {
+	row := q.db.QueryRowContext(ctx, updateUserEmailChange, arg.ID, arg.EmailChange)
+	var i AuthUser
+	err := row.Scan(
+		&i.ID,
+		&i.Phone,
+		&i.Email,
+		&i.Username,
+		&i.EncryptedPassword,
+		&i.MetaAvatar,
+		&i.MetaFirstName,
+		&i.MetaLastName,
+		&i.MetaBirthdate,
+		&i.MetaExtra,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.LastSignIn,
+		&i.EmailConfirmedAt,
+		&i.PhoneConfirmedAt,
+		&i.BannedUntil,
+		&i.DeletedAt,
+		&i.EmailChange,
+	)
+	return &i, err
+}
+
+const confirmUserEmailChange = `-- name: ConfirmUserEmailChange :one
+update auth.users
+set email              = email_change,
+    email_change       = null,
+    email_confirmed_at = now(),
+    updated_at         = now()
+where id = $1
+  and email_change is not null
+returning id, phone, email, username, encrypted_password, meta_avatar, meta_first_name, meta_last_name, meta_birthdate, meta_extra, created_at, updated_at, last_sign_in, email_confirmed_at, phone_confirmed_at, banned_until, deleted_at, email_change
+`
+
+func (q *Queries) ConfirmUserEmailChange(ctx context.Context, id uuid.UUID) (*AuthUser, error) {
+	row := q.db.QueryRowContext(ctx, confirmUserEmailChange, id)
+	var i AuthUser
+	err := row.Scan(
+		&i.ID,
+		&i.Phone,
+		&i.Email,
+		&i.Username,
+		&i.EncryptedPassword,
+		&i.MetaAvatar,
+		&i.MetaFirstName,
+		&i.MetaLastName,
+		&i.MetaBirthdate,
+		&i.MetaExtra,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.LastSignIn,
+		&i.EmailConfirmedAt,
+		&i.PhoneConfirmedAt,
+		&i.BannedUntil,
+		&i.DeletedAt,
+		&i.EmailChange,
 	)
 	return &i, err
 }
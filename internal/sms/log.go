@@ -0,0 +1,18 @@
+package sms
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+)
+
+// logSender writes messages to the log instead of sending them, for development and testing only
+type logSender struct{}
+
+func (s *logSender) Send(ctx context.Context, message Message) error {
+	logrus.WithContext(ctx).
+		WithField("component", "sms").
+		WithField("to", message.To).
+		Infof("SMS: %s", message.Body)
+
+	return nil
+}
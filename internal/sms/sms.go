@@ -0,0 +1,27 @@
+package sms
+
+import (
+	"context"
+	"surge/internal/conf"
+)
+
+// Message is a text message to be sent to a single phone number
+type Message struct {
+	To   string
+	Body string
+}
+
+// SMSSender delivers text messages to users
+type SMSSender interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// NewSMSSender returns the SMSSender for the configured driver
+func NewSMSSender(config *conf.SurgeSMSConfigurations) SMSSender {
+	switch config.Driver {
+	case conf.SMSDriverTwilio:
+		return newTwilioSender(&config.Twilio)
+	default:
+		return &logSender{}
+	}
+}
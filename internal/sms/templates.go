@@ -0,0 +1,11 @@
+package sms
+
+import "fmt"
+
+// NewOTPMessage creates a message with the code to confirm the phone and sign in
+func NewOTPMessage(to string, code string) Message {
+	return Message{
+		To:   to,
+		Body: fmt.Sprintf("Your verification code is %s", code),
+	}
+}
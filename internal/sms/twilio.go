@@ -0,0 +1,67 @@
+package sms
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"surge/internal/conf"
+	"time"
+)
+
+// twilioSender sends messages through the Twilio Programmable Messaging API
+type twilioSender struct {
+	config *conf.SurgeTwilioConfigurations
+	client *http.Client
+}
+
+type twilioErrorResponse struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func newTwilioSender(config *conf.SurgeTwilioConfigurations) *twilioSender {
+	return &twilioSender{
+		config: config,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (s *twilioSender) Send(ctx context.Context, message Message) error {
+	endpoint := fmt.Sprintf("%s/2010-04-01/Accounts/%s/Messages.json", strings.TrimSuffix(s.config.BaseURL, "/"), s.config.AccountSID)
+
+	form := url.Values{}
+	form.Set("To", message.To)
+	form.Set("Body", message.Body)
+	if strings.HasPrefix(s.config.From, "MG") {
+		form.Set("MessagingServiceSid", s.config.From)
+	} else {
+		form.Set("From", s.config.From)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(s.config.AccountSID, s.config.AuthToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= http.StatusMultipleChoices {
+		var errorResponse twilioErrorResponse
+		if err := json.NewDecoder(res.Body).Decode(&errorResponse); err != nil {
+			return fmt.Errorf("twilio responded with status %d", res.StatusCode)
+		}
+		return fmt.Errorf("twilio responded with status %d: %s (code %d)", res.StatusCode, errorResponse.Message, errorResponse.Code)
+	}
+
+	return nil
+}
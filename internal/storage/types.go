@@ -49,6 +49,19 @@ func NullStringToPointer(str sql.NullString) *string {
 	return nil
 }
 
+// InterfaceToStringPointer converts a nullable column of a type unknown to sqlc (e.g. domains) to *string
+func InterfaceToStringPointer(v interface{}) *string {
+	switch value := v.(type) {
+	case string:
+		return &value
+	case []byte:
+		str := string(value)
+		return &str
+	default:
+		return nil
+	}
+}
+
 func NullTimeToPointer(t sql.NullTime) *time.Time {
 	if t.Valid {
 		return &t.Time
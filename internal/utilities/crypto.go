@@ -1,9 +1,13 @@
 package utilities
 
 import (
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"io"
+	"math/big"
 )
 
 type SecureTokenOption interface{}
@@ -34,3 +38,23 @@ func SecureToken(options ...SecureTokenOption) string {
 	}
 	return base64.RawURLEncoding.EncodeToString(b)
 }
+
+// HashToken creates a keyed hash of the token, so it can be stored and looked up without storing the token itself
+func HashToken(token string, key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SecureDigits creates a new random numeric code with the given amount of digits
+func SecureDigits(digits int) string {
+	code := make([]byte, digits)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			panic(err.Error()) // rand should never fail
+		}
+		code[i] = byte('0' + n.Int64())
+	}
+	return string(code)
+}
@@ -0,0 +1,26 @@
+package utilities
+
+// MergeJSONPatch applies patch onto target following JSON merge patch (RFC 7396) semantics,
+// nil values in patch remove the key from target and nested objects are merged recursively
+func MergeJSONPatch(target map[string]interface{}, patch map[string]interface{}) map[string]interface{} {
+	if target == nil {
+		target = make(map[string]interface{})
+	}
+
+	for key, value := range patch {
+		if value == nil {
+			delete(target, key)
+			continue
+		}
+
+		if patchObject, ok := value.(map[string]interface{}); ok {
+			targetObject, _ := target[key].(map[string]interface{})
+			target[key] = MergeJSONPatch(targetObject, patchObject)
+			continue
+		}
+
+		target[key] = value
+	}
+
+	return target
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"github.com/sirupsen/logrus"
 	"io"
+	"net"
 	"net/http"
 )
 
@@ -42,3 +43,13 @@ func GetBodyJson[T any](req *http.Request) (*T, error) {
 	}
 	return &value, nil
 }
+
+// GetIPAddress returns the client ip address of the request, without the port
+func GetIPAddress(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+
+	return host
+}
@@ -0,0 +1,20 @@
+create table if not exists auth.sessions
+(
+    id           uuid                     not null unique default gen_random_uuid(),
+    user_id      uuid                     not null references auth.users (id) on delete cascade,
+
+    user_agent   text                     null,
+    ip_address   varchar(64)              null,
+
+    created_at   timestamp with time zone not null,
+    updated_at   timestamp with time zone not null,
+    refreshed_at timestamp with time zone null            default null,
+
+    constraint sessions_pkey primary key (id)
+);
+create index if not exists sessions_id_index on auth.sessions using brin (id);
+create index if not exists sessions_user_id_index on auth.sessions (user_id);
+
+alter table auth.refresh_tokens
+    add column if not exists session_id uuid null references auth.sessions (id) on delete cascade;
+create index if not exists refresh_tokens_session_id_index on auth.refresh_tokens (session_id);
@@ -0,0 +1,3 @@
+alter table auth.refresh_tokens
+    add column if not exists parent_id bigint null references auth.refresh_tokens (id) on delete set null;
+create index if not exists refresh_tokens_parent_id_index on auth.refresh_tokens (parent_id);
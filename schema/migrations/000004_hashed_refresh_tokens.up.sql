@@ -0,0 +1,12 @@
+-- Refresh tokens are stored as keyed hashes, rows created before are kept as plaintext until they are rotated
+alter table auth.refresh_tokens
+    add column if not exists hashed bool not null default false;
+
+-- Revoked plaintext tokens can never be exchanged again, there is no reason to keep them readable
+update auth.refresh_tokens
+set token = null
+where hashed = false
+  and revoked = true;
+
+drop index if exists auth.refresh_tokens_token_index;
+create index if not exists refresh_tokens_token_index on auth.refresh_tokens (token);
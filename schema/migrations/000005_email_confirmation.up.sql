@@ -0,0 +1,25 @@
+alter table auth.users
+    add column if not exists email_confirmed_at timestamp with time zone null default null;
+
+-- Emails were always confirmed automatically before confirmation was supported
+update auth.users
+set email_confirmed_at = created_at
+where email is not null
+  and email_confirmed_at is null;
+
+create table if not exists auth.one_time_tokens
+(
+    id         uuid                     not null unique default gen_random_uuid(),
+    user_id    uuid                     not null references auth.users (id) on delete cascade,
+
+    token_type text                     not null,
+    token_hash varchar(255)             not null,
+    relates_to text                     not null,
+
+    created_at timestamp with time zone not null,
+    expires_at timestamp with time zone not null,
+
+    constraint one_time_tokens_pkey primary key (id),
+    constraint one_time_tokens_user_id_token_type_key unique (user_id, token_type)
+);
+create index if not exists one_time_tokens_token_hash_index on auth.one_time_tokens (token_type, token_hash);
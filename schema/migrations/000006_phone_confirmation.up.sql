@@ -0,0 +1,7 @@
+alter table auth.users
+    add column if not exists phone_confirmed_at timestamp with time zone null default null;
+
+-- Phones are stored in E.164 format, which is what is validated and what SMS providers expect.
+-- Existing rows are not validated against the new format.
+alter domain auth.phone drop constraint if exists phone_check;
+alter domain auth.phone add constraint phone_check check ( VALUE ~ '^\+[1-9]\d{1,14}$' ) not valid;
@@ -0,0 +1,35 @@
+create table if not exists auth.mfa_factors
+(
+    id            uuid                     not null unique default gen_random_uuid(),
+    user_id       uuid                     not null references auth.users (id) on delete cascade,
+
+    factor_type   text                     not null,
+    status        text                     not null,
+    friendly_name text                     null,
+    secret        text                     null,
+
+    created_at    timestamp with time zone not null,
+    updated_at    timestamp with time zone not null,
+
+    constraint mfa_factors_pkey primary key (id)
+);
+create index if not exists mfa_factors_user_id_index on auth.mfa_factors (user_id);
+
+create table if not exists auth.mfa_challenges
+(
+    id          uuid                     not null unique default gen_random_uuid(),
+    factor_id   uuid                     not null references auth.mfa_factors (id) on delete cascade,
+
+    ip_address  varchar(64)              null,
+
+    created_at  timestamp with time zone not null,
+    verified_at timestamp with time zone null default null,
+
+    constraint mfa_challenges_pkey primary key (id)
+);
+create index if not exists mfa_challenges_factor_id_index on auth.mfa_challenges (factor_id);
+
+-- Authenticator assurance level and the methods used to authenticate within the session
+alter table auth.sessions
+    add column if not exists aal text  not null default 'aal1',
+    add column if not exists amr jsonb not null default '[]';
@@ -0,0 +1,32 @@
+-- Credentials of verified webauthn factors
+create table if not exists auth.webauthn_credentials
+(
+    id            uuid                     not null unique default gen_random_uuid(),
+    factor_id     uuid                     not null unique references auth.mfa_factors (id) on delete cascade,
+    user_id       uuid                     not null references auth.users (id) on delete cascade,
+
+    credential_id bytea                    not null unique,
+    credential    jsonb                    not null,
+
+    created_at    timestamp with time zone not null,
+    updated_at    timestamp with time zone not null,
+    last_used_at  timestamp with time zone null default null,
+
+    constraint webauthn_credentials_pkey primary key (id)
+);
+create index if not exists webauthn_credentials_user_id_index on auth.webauthn_credentials (user_id);
+
+-- Ceremony state of factor challenges of webauthn factors
+alter table auth.mfa_challenges
+    add column if not exists webauthn_session_data jsonb null default null;
+
+-- Ceremony state of signing in with a passkey, where the user is not known until the assertion
+create table if not exists auth.webauthn_challenges
+(
+    id           uuid                     not null unique default gen_random_uuid(),
+    session_data jsonb                    not null,
+
+    created_at   timestamp with time zone not null,
+
+    constraint webauthn_challenges_pkey primary key (id)
+);
@@ -0,0 +1,30 @@
+-- One-time codes satisfying an mfa challenge when the factors of the user are lost
+create table if not exists auth.mfa_recovery_codes
+(
+    id         uuid                     not null unique default gen_random_uuid(),
+    user_id    uuid                     not null references auth.users (id) on delete cascade,
+
+    code_hash  varchar(255)             not null,
+
+    created_at timestamp with time zone not null,
+    used_at    timestamp with time zone null default null,
+
+    constraint mfa_recovery_codes_pkey primary key (id)
+);
+create index if not exists mfa_recovery_codes_user_id_code_hash_index on auth.mfa_recovery_codes (user_id, code_hash);
+
+-- Security relevant events of users, kept after the user is deleted
+create table if not exists auth.audit_log_entries
+(
+    id         uuid                     not null unique default gen_random_uuid(),
+    user_id    uuid                     null,
+
+    action     text                     not null,
+    ip_address varchar(64)              null,
+    payload    jsonb                    not null default '{}',
+
+    created_at timestamp with time zone not null,
+
+    constraint audit_log_entries_pkey primary key (id)
+);
+create index if not exists audit_log_entries_user_id_index on auth.audit_log_entries (user_id, created_at);
@@ -0,0 +1,3 @@
+-- Banned users can't sign in or refresh their sessions until the ban expires
+alter table auth.users
+    add column if not exists banned_until timestamp with time zone null default null;
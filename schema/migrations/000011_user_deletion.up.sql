@@ -0,0 +1,18 @@
+-- Rows left behind by users deleted before foreign keys existed
+delete
+from auth.identities
+where user_id not in (select id from auth.users);
+delete
+from auth.refresh_tokens
+where user_id is not null
+  and user_id not in (select id from auth.users);
+
+alter table auth.identities
+    add constraint identities_user_id_fkey foreign key (user_id) references auth.users (id) on delete cascade;
+alter table auth.refresh_tokens
+    add constraint refresh_tokens_user_id_fkey foreign key (user_id) references auth.users (id) on delete cascade;
+
+-- Soft deleted users are anonymized and kept for auditing until they are purged
+alter table auth.users
+    add column if not exists deleted_at timestamp with time zone null default null;
+create index if not exists users_deleted_at_index on auth.users (deleted_at) where deleted_at is not null;
@@ -0,0 +1,9 @@
+-- Wrong codes invalidate challenges and lock factors to prevent guessing codes
+alter table auth.mfa_challenges
+    add column if not exists failed_attempts integer not null default 0;
+
+alter table auth.mfa_factors
+    add column if not exists failed_attempts integer                  not null default 0,
+    add column if not exists locked_until    timestamp with time zone null default null,
+    -- TOTP time step of the last accepted code, codes of it or earlier steps are rejected
+    add column if not exists last_totp_step  bigint                   null default null;
@@ -0,0 +1,3 @@
+-- Wrong codes are counted so a numeric code is deleted before it can be guessed
+alter table auth.one_time_tokens
+    add column if not exists failed_attempts integer not null default 0;
@@ -0,0 +1,3 @@
+-- New email the user asked to change to, it replaces the email once the new one is confirmed
+alter table auth.users
+    add column if not exists email_change text null default null;
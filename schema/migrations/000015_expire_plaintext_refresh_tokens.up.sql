@@ -0,0 +1,9 @@
+-- Plaintext refresh tokens are only exchangeable for 30 days after being issued, older ones are revoked and erased
+-- so no usable plaintext token stays stored once they are no longer hashed on use
+update auth.refresh_tokens
+set token      = null,
+    revoked    = true,
+    updated_at = now()
+where hashed = false
+  and token is not null
+  and created_at < now() - interval '30 days';
@@ -0,0 +1,3 @@
+-- name: CreateAuditLogEntry :exec
+insert into auth.audit_log_entries(user_id, action, ip_address, payload, created_at)
+values ($1, $2, $3, $4, now());
@@ -35,4 +35,14 @@ UPDATE auth.identities
 Set updated_at   = now(),
     last_sign_in = now()
 WHERE id = $1
-RETURNING *;
\ No newline at end of file
+RETURNING *;
+
+-- name: DeleteIdentitiesOfUser :exec
+DELETE
+from auth.identities
+WHERE user_id = $1;
+
+-- name: DeleteIdentity :exec
+DELETE
+from auth.identities
+WHERE id = $1;
@@ -0,0 +1,87 @@
+-- name: CreateFactor :one
+insert into auth.mfa_factors(user_id, factor_type, status, friendly_name, secret, created_at, updated_at)
+values ($1, $2, $3, $4, $5, now(), now())
+returning *;
+
+-- name: GetFactor :one
+select *
+from auth.mfa_factors
+where id = $1;
+
+-- name: GetFactorForUpdate :one
+select *
+from auth.mfa_factors
+where id = $1 for update;
+
+-- name: ListFactorsOfUser :many
+select *
+from auth.mfa_factors
+where user_id = $1
+order by created_at;
+
+-- name: ListVerifiedFactorsOfUser :many
+select *
+from auth.mfa_factors
+where user_id = $1
+  and status = 'verified'
+order by created_at;
+
+-- name: UpdateFactorStatus :one
+update auth.mfa_factors
+set status     = $2,
+    updated_at = now()
+where id = $1
+returning *;
+
+-- name: UpdateFactorFailedAttempts :one
+update auth.mfa_factors
+set failed_attempts = $2,
+    locked_until    = $3,
+    updated_at      = now()
+where id = $1
+returning *;
+
+-- name: UpdateFactorLastTotpStep :one
+update auth.mfa_factors
+set last_totp_step  = $2,
+    failed_attempts = 0,
+    locked_until    = null,
+    updated_at      = now()
+where id = $1
+returning *;
+
+-- name: DeleteFactor :exec
+delete
+from auth.mfa_factors
+where id = $1;
+
+-- name: DeleteFactorsOfUser :exec
+delete
+from auth.mfa_factors
+where user_id = $1;
+
+-- name: CreateChallenge :one
+insert into auth.mfa_challenges(factor_id, ip_address, webauthn_session_data, created_at)
+values ($1, $2, $3, now())
+returning *;
+
+-- name: GetChallenge :one
+select *
+from auth.mfa_challenges
+where id = $1 for update;
+
+-- name: UpdateChallengeVerifiedAt :exec
+update auth.mfa_challenges
+set verified_at = now()
+where id = $1;
+
+-- name: IncrementChallengeFailedAttempts :one
+update auth.mfa_challenges
+set failed_attempts = failed_attempts + 1
+where id = $1
+returning failed_attempts;
+
+-- name: DeleteChallenge :exec
+delete
+from auth.mfa_challenges
+where id = $1;
@@ -0,0 +1,39 @@
+-- name: CreateOneTimeToken :one
+insert into auth.one_time_tokens(user_id, token_type, token_hash, relates_to, created_at, expires_at)
+values ($1, $2, $3, $4, now(), $5)
+on conflict (user_id, token_type) do update set token_hash = excluded.token_hash,
+                                                relates_to = excluded.relates_to,
+                                                created_at = excluded.created_at,
+                                                expires_at = excluded.expires_at,
+                                                failed_attempts = 0
+returning *;
+
+-- name: GetOneTimeToken :one
+select *
+from auth.one_time_tokens
+where token_type = $1
+  and token_hash = $2
+    for update;
+
+-- name: DeleteOneTimeToken :exec
+delete
+from auth.one_time_tokens
+where id = $1;
+
+-- name: DeleteOneTimeTokensOfUser :exec
+delete
+from auth.one_time_tokens
+where user_id = $1;
+
+-- name: GetOneTimeTokenOfUser :one
+select *
+from auth.one_time_tokens
+where user_id = $1
+  and token_type = $2
+    for update;
+
+-- name: IncrementOneTimeTokenFailedAttempts :one
+update auth.one_time_tokens
+set failed_attempts = failed_attempts + 1
+where id = $1
+returning failed_attempts;
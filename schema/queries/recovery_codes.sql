@@ -0,0 +1,26 @@
+-- name: CreateRecoveryCode :exec
+insert into auth.mfa_recovery_codes(user_id, code_hash, created_at)
+values ($1, $2, now());
+
+-- name: GetUnusedRecoveryCode :one
+select *
+from auth.mfa_recovery_codes
+where user_id = $1
+  and code_hash = $2
+  and used_at is null for update;
+
+-- name: CountUnusedRecoveryCodesOfUser :one
+select count(*)
+from auth.mfa_recovery_codes
+where user_id = $1
+  and used_at is null;
+
+-- name: UpdateRecoveryCodeUsedAt :exec
+update auth.mfa_recovery_codes
+set used_at = now()
+where id = $1;
+
+-- name: DeleteRecoveryCodesOfUser :exec
+delete
+from auth.mfa_recovery_codes
+where user_id = $1;
@@ -0,0 +1,46 @@
+-- name: CreateSession :one
+insert into auth.sessions(user_id, user_agent, ip_address, aal, amr, created_at, updated_at, refreshed_at)
+values ($1, $2, $3, $4, $5, now(), now(), null)
+returning *;
+
+-- name: GetSession :one
+select *
+from auth.sessions
+where id = $1;
+
+-- name: ListSessionsByUser :many
+select *
+from auth.sessions
+where user_id = $1
+order by created_at desc;
+
+-- name: UpdateSessionRefreshedAt :one
+update auth.sessions
+set updated_at   = now(),
+    refreshed_at = now()
+where id = $1
+returning *;
+
+-- name: DeleteSession :exec
+delete
+from auth.sessions
+where id = $1;
+
+-- name: DeleteSessionsOfUser :exec
+delete
+from auth.sessions
+where user_id = $1;
+
+-- name: DeleteOtherSessionsOfUser :exec
+delete
+from auth.sessions
+where user_id = $1
+  and id <> sqlc.arg('current_session_id')::uuid;
+
+-- name: UpdateSessionAuthentication :one
+update auth.sessions
+set aal        = $2,
+    amr        = $3,
+    updated_at = now()
+where id = $1
+returning *;
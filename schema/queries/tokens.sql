@@ -1,6 +1,6 @@
 -- name: CreateRefreshToken :one
-insert into auth.refresh_tokens(user_id, token, revoked, created_at, updated_at)
-values ($1, $2, $3, now(), now())
+insert into auth.refresh_tokens(user_id, session_id, parent_id, token, hashed, revoked, created_at, updated_at)
+values ($1, $2, $3, $4, true, $5, now(), now())
 returning *;
 
 -- name: ListRefreshTokenByUser :many
@@ -11,14 +11,56 @@ where user_id = $1;
 -- name: GetRefreshToken :one
 select *
 from auth.refresh_tokens
-where token = sqlc.arg('token')::varchar and revoked = false;
+where (hashed = true and token = sqlc.arg('token_hash')::varchar)
+   or (hashed = false and token = sqlc.arg('token')::varchar and created_at > now() - interval '30 days')
+    for update;
+
+-- name: GetLatestRefreshTokenChild :one
+select *
+from auth.refresh_tokens
+where parent_id = $1
+order by id desc
+limit 1;
+
+-- name: HashRefreshToken :exec
+update auth.refresh_tokens
+set token  = $2,
+    hashed = true
+where id = $1;
 
 -- name: RevokeRefreshToken :exec
 update auth.refresh_tokens
-set revoked = true
+set revoked    = true,
+    updated_at = now()
 where id = $1;
 
+-- name: RevokeRefreshTokenDescendants :exec
+with recursive descendants as (select id
+                               from auth.refresh_tokens
+                               where parent_id = $1
+                               union all
+                               select child.id
+                               from auth.refresh_tokens child
+                                        join descendants on child.parent_id = descendants.id)
+update auth.refresh_tokens
+set revoked    = true,
+    updated_at = now()
+where id in (select id from descendants);
+
 -- name: RevokeRefreshTokensOfUser :exec
 update auth.refresh_tokens
-set revoked = true
-where user_id = $1;
\ No newline at end of file
+set revoked    = true,
+    updated_at = now()
+where user_id = $1;
+
+-- name: RevokeSessionlessRefreshTokensOfUser :exec
+update auth.refresh_tokens
+set revoked    = true,
+    updated_at = now()
+where user_id = $1
+  and session_id is null;
+
+-- name: DeleteRefreshTokensOfUser :exec
+delete
+from auth.refresh_tokens
+where user_id = $1;
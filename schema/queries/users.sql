@@ -30,15 +30,16 @@ select *
 from auth.users
 where username = sqlc.arg('username')::varchar;
 
--- name: GetUserByRefreshToken :one
+-- name: GetUserByPhone :one
 select *
 from auth.users
-where (select * from auth.refresh_tokens where token = sqlc.arg('token')::varchar);
+where phone = sqlc.arg('phone')::text;
 
 -- name: UpdateUser :one
 update auth.users
 set email              = coalesce(sqlc.narg('email'), email),
     username           = coalesce(sqlc.narg('username'), username),
+    phone              = coalesce(sqlc.narg('phone'), phone),
     encrypted_password = coalesce(sqlc.narg('encrypted_password'), encrypted_password),
 
     created_at         = coalesce(sqlc.narg('created_at'), created_at),
@@ -51,9 +52,10 @@ returning *;
 update auth.users
 set meta_avatar     = coalesce(sqlc.narg('meta_avatar'), meta_avatar),
     meta_first_name = coalesce(sqlc.narg('meta_first_name'), meta_first_name),
-    meta_last_name  = coalesce(sqlc.narg('meta_last_name'), meta_first_name),
-    meta_birthdate  = coalesce(sqlc.narg('meta_birthdate'), meta_first_name),
-    meta_extra      = coalesce(sqlc.narg('meta_extra'), meta_extra)
+    meta_last_name  = coalesce(sqlc.narg('meta_last_name'), meta_last_name),
+    meta_birthdate  = coalesce(sqlc.narg('meta_birthdate'), meta_birthdate),
+    meta_extra      = coalesce(sqlc.narg('meta_extra'), meta_extra),
+    updated_at      = now()
 where id = $1
 returning *;
 
@@ -63,4 +65,89 @@ update auth.users
 set updated_at   = now(),
     last_sign_in = now()
 where id = $1
-returning *;
\ No newline at end of file
+returning *;
+
+-- name: UpdateUserEmailConfirmedAt :one
+update auth.users
+set email_confirmed_at = sqlc.narg('email_confirmed_at'),
+    updated_at         = now()
+where id = $1
+returning *;
+-- name: UpdateUserPhoneConfirmedAt :one
+update auth.users
+set phone_confirmed_at = sqlc.narg('phone_confirmed_at'),
+    updated_at         = now()
+where id = $1
+returning *;
+
+-- name: ListUsers :many
+select *
+from auth.users
+where sqlc.arg('query')::text = ''
+   or email ilike '%' || sqlc.arg('query')::text || '%' escape '\'
+   or username ilike '%' || sqlc.arg('query')::text || '%' escape '\'
+   or phone ilike '%' || sqlc.arg('query')::text || '%' escape '\'
+order by created_at desc, id
+limit sqlc.arg('limit') offset sqlc.arg('offset');
+
+-- name: CountUsers :one
+select count(*)
+from auth.users
+where sqlc.arg('query')::text = ''
+   or email ilike '%' || sqlc.arg('query')::text || '%' escape '\'
+   or username ilike '%' || sqlc.arg('query')::text || '%' escape '\'
+   or phone ilike '%' || sqlc.arg('query')::text || '%' escape '\';
+
+-- name: UpdateUserBannedUntil :one
+update auth.users
+set banned_until = sqlc.narg('banned_until'),
+    updated_at   = now()
+where id = $1
+returning *;
+
+-- name: DeleteUser :exec
+delete
+from auth.users
+where id = $1;
+
+-- name: SoftDeleteUser :one
+update auth.users
+set phone              = null,
+    email              = null,
+    email_change       = null,
+    username           = null,
+    encrypted_password = null,
+    meta_avatar        = null,
+    meta_first_name    = null,
+    meta_last_name     = null,
+    meta_birthdate     = null,
+    meta_extra         = '{}',
+    email_confirmed_at = null,
+    phone_confirmed_at = null,
+    banned_until       = null,
+    updated_at         = now(),
+    deleted_at         = now()
+where id = $1
+returning *;
+
+-- name: PurgeDeletedUsers :execrows
+delete
+from auth.users
+where deleted_at < sqlc.arg('deleted_before')::timestamptz;
+
+-- name: UpdateUserEmailChange :one
+update auth.users
+set email_change = sqlc.narg('email_change'),
+    updated_at   = now()
+where id = $1
+returning *;
+
+-- name: ConfirmUserEmailChange :one
+update auth.users
+set email              = email_change,
+    email_change       = null,
+    email_confirmed_at = now(),
+    updated_at         = now()
+where id = $1
+  and email_change is not null
+returning *;
@@ -0,0 +1,37 @@
+-- name: CreateWebAuthnCredential :one
+insert into auth.webauthn_credentials(factor_id, user_id, credential_id, credential, created_at, updated_at)
+values ($1, $2, $3, $4, now(), now())
+returning *;
+
+-- name: GetWebAuthnCredentialByFactor :one
+select *
+from auth.webauthn_credentials
+where factor_id = $1;
+
+-- name: ListWebAuthnCredentialsOfUser :many
+select *
+from auth.webauthn_credentials
+where user_id = $1
+order by created_at;
+
+-- name: UpdateWebAuthnCredential :exec
+update auth.webauthn_credentials
+set credential   = $2,
+    updated_at   = now(),
+    last_used_at = now()
+where id = $1;
+
+-- name: CreateWebAuthnChallenge :one
+insert into auth.webauthn_challenges(session_data, created_at)
+values ($1, now())
+returning *;
+
+-- name: GetWebAuthnChallenge :one
+select *
+from auth.webauthn_challenges
+where id = $1 for update;
+
+-- name: DeleteWebAuthnChallenge :exec
+delete
+from auth.webauthn_challenges
+where id = $1;